@@ -0,0 +1,159 @@
+package gocal
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/apognu/gocal/parser"
+)
+
+func (gc *Gocal) parseAlarm(l *Line) error {
+	if gc.buffer == nil || len(gc.buffer.Alarms) == 0 {
+		return nil
+	}
+
+	a := &gc.buffer.Alarms[len(gc.buffer.Alarms)-1]
+
+	switch l.Key {
+	case "ACTION":
+		if err := resolve(gc, l, &a.Action, resolveString, nil); err != nil {
+			return err
+		}
+	case "TRIGGER":
+		if err := resolve(gc, l, &a.Trigger, resolveTrigger, nil); err != nil {
+			return err
+		}
+	case "REPEAT":
+		if err := resolve(gc, l, &a.Repeat, resolveInt, nil); err != nil {
+			return err
+		}
+	case "DURATION":
+		if err := resolve(gc, l, &a.Duration, resolveDuration, nil); err != nil {
+			return err
+		}
+	case "DESCRIPTION":
+		if err := resolve(gc, l, &a.Description, resolveString, nil); err != nil {
+			return err
+		}
+	case "SUMMARY":
+		if err := resolve(gc, l, &a.Summary, resolveString, nil); err != nil {
+			return err
+		}
+	case "ATTENDEE":
+		a.Attendees = append(a.Attendees, parseAttendee(l))
+	case "ATTACH":
+		a.Attachments = append(a.Attachments, parseAttachment(l))
+	default:
+		key := strings.ToUpper(l.Key)
+		if strings.HasPrefix(key, "X-") {
+			if a.CustomAttributes == nil {
+				a.CustomAttributes = make(map[string]string)
+			}
+			a.CustomAttributes[key] = l.Value
+		}
+	}
+
+	return nil
+}
+
+func resolveTrigger(gc *Gocal, l *Line) (*Trigger, *Trigger, error) {
+	// Reference: https://icalendar.org/iCalendar-RFC-5545/3-8-6-3-trigger.html
+	if l.Params["VALUE"] == "DATE-TIME" {
+		d, err := gc.parseTime(l.Value, l.Params, parser.TimeStart, false)
+		if err != nil {
+			return nil, nil, fmt.Errorf("could not parse: %s", err)
+		}
+
+		return &Trigger{Time: d}, nil, nil
+	}
+
+	d, err := parser.ParseDuration(l.Value)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not parse: %s", err)
+	}
+
+	related := TriggerRelatedStart
+	if l.Params["RELATED"] == TriggerRelatedEnd {
+		related = TriggerRelatedEnd
+	}
+
+	return &Trigger{Related: related, Duration: d}, nil, nil
+}
+
+// FireTimes computes the instants at which the alarm goes off for the given event occurrence,
+// including repetitions.
+func (a Alarm) FireTimes(e Event) []time.Time {
+	if a.Trigger == nil {
+		return []time.Time{}
+	}
+
+	var first time.Time
+
+	switch {
+	case a.Trigger.Time != nil:
+		first = *a.Trigger.Time
+	case a.Trigger.Related == TriggerRelatedEnd && e.End != nil:
+		first = e.End.Add(*a.Trigger.Duration)
+	case e.Start != nil:
+		first = e.Start.Add(*a.Trigger.Duration)
+	default:
+		return []time.Time{}
+	}
+
+	times := []time.Time{first}
+
+	// REPEAT and DURATION must both be present for the alarm to be repeated
+	if a.Duration != nil {
+		for i := 1; i <= a.Repeat; i++ {
+			times = append(times, first.Add(time.Duration(i)*(*a.Duration)))
+		}
+	}
+
+	return times
+}
+
+// AlarmOccurrences lists when every alarm of the parsed events goes off, for each occurrence within
+// Gocal.Start and Gocal.End. Recurring events returned by RecurrenceModeMasters are expanded, along with
+// their overrides. Alarms with an absolute trigger are only reported once per event UID.
+// Events returned by Next() are not kept, their alarms can be computed with Alarm.FireTimes().
+func (gc *Gocal) AlarmOccurrences() []AlarmOccurrence {
+	events := make([]*Event, 0, len(gc.Events))
+	for i := range gc.Events {
+		e := &gc.Events[i]
+
+		switch {
+		case e.IsRecurring && e.RecurrenceID == nil && !e.IsOccurrence():
+			instances := gc.Occurrences(e, *gc.Start, *gc.End)
+			for k := range instances {
+				events = append(events, &instances[k])
+			}
+		case gc.Recurrence.Mode == RecurrenceModeMasters && e.IsOverride() && gc.masters[e.Uid] != nil:
+			// Overrides are part of the occurrences of their recurring event
+		default:
+			events = append(events, e)
+		}
+	}
+
+	occurrences := make([]AlarmOccurrence, 0)
+	seen := make(map[string]bool)
+
+	for _, e := range events {
+		for j := range e.Alarms {
+			a := &e.Alarms[j]
+
+			for _, t := range a.FireTimes(*e) {
+				key := e.Uid + "/" + strconv.Itoa(j) + "/" + t.Format(time.RFC3339Nano)
+				if seen[key] {
+					continue
+				}
+				seen[key] = true
+
+				occurrences = append(occurrences, AlarmOccurrence{Event: e, Alarm: a, Time: t})
+			}
+		}
+	}
+
+	return occurrences
+}
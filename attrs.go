@@ -2,6 +2,8 @@ package gocal
 
 import (
 	"fmt"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/apognu/gocal/parser"
@@ -26,6 +28,8 @@ func resolve[T comparable](gc *Gocal, l *Line, dst *T, resolve func(gc *Gocal, l
 		if gc.Duplicate.Mode == DuplicateModeFailStrict {
 			return NewDuplicateAttribute(l.Key, l.Value)
 		}
+
+		gc.warn(SeverityWarning, NewDuplicateAttribute(l.Key, l.Value))
 	}
 
 	// If the value is empty or the duplicate mode allows further processing, set the value
@@ -45,7 +49,7 @@ func resolveString(gc *Gocal, l *Line) (string, string, error) {
 }
 
 func resolveDate(gc *Gocal, l *Line) (*time.Time, *time.Time, error) {
-	d, err := parser.ParseTime(l.Value, l.Params, parser.TimeStart, false, gc.AllDayEventsTZ)
+	d, err := gc.parseTime(l.Value, l.Params, parser.TimeStart, false)
 	if err != nil {
 		return nil, nil, fmt.Errorf("could not parse: %s", err)
 	}
@@ -54,7 +58,7 @@ func resolveDate(gc *Gocal, l *Line) (*time.Time, *time.Time, error) {
 }
 
 func resolveDateEnd(gc *Gocal, l *Line) (*time.Time, *time.Time, error) {
-	d, err := parser.ParseTime(l.Value, l.Params, parser.TimeEnd, false, gc.AllDayEventsTZ)
+	d, err := gc.parseTime(l.Value, l.Params, parser.TimeEnd, false)
 	if err != nil {
 		return nil, nil, fmt.Errorf("could not parse: %s", err)
 	}
@@ -62,6 +66,15 @@ func resolveDateEnd(gc *Gocal, l *Line) (*time.Time, *time.Time, error) {
 	return d, nil, nil
 }
 
+func resolveRRule(gc *Gocal, l *Line) (*RRule, *RRule, error) {
+	rule, err := ParseRRule(l.Value)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return rule, nil, nil
+}
+
 func resolveDuration(gc *Gocal, l *Line) (*time.Duration, *time.Duration, error) {
 	d, err := parser.ParseDuration(l.Value)
 	if err != nil {
@@ -89,3 +102,65 @@ func resolveGeo(gc *Gocal, l *Line) (*Geo, *Geo, error) {
 
 	return &Geo{lat, long}, nil, nil
 }
+
+func resolveInt(gc *Gocal, l *Line) (int, int, error) {
+	i, err := strconv.Atoi(strings.TrimSpace(l.Value))
+	if err != nil {
+		return 0, 0, fmt.Errorf("could not parse: %s", err)
+	}
+
+	return i, 0, nil
+}
+
+// parsePeriod parses a PERIOD value, either as an explicit start and end, or as a start and a duration.
+// Reference: https://icalendar.org/iCalendar-RFC-5545/3-3-9-period-of-time.html
+func (gc *Gocal) parsePeriod(s string, params map[string]string) (*time.Time, *time.Time, error) {
+	tokens := strings.SplitN(s, "/", 2)
+	if len(tokens) != 2 {
+		return nil, nil, fmt.Errorf("could not parse period: %s", s)
+	}
+
+	start, err := gc.parseTime(tokens[0], params, parser.TimeStart, false)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not parse period start: %s", err)
+	}
+
+	if strings.HasPrefix(tokens[1], "P") || strings.HasPrefix(tokens[1], "+P") {
+		d, err := parser.ParseDuration(tokens[1])
+		if err != nil {
+			return nil, nil, fmt.Errorf("could not parse period duration: %s", err)
+		}
+
+		end := start.Add(*d)
+
+		return start, &end, nil
+	}
+
+	end, err := gc.parseTime(tokens[1], params, parser.TimeStart, false)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not parse period end: %s", err)
+	}
+
+	return start, end, nil
+}
+
+// parseExcludeDates parses the comma-separated values of an EXDATE, separating date-times from
+// VALUE=DATE dates, which exclude a whole calendar day. Unparsable values are dropped with a warning.
+// Reference: https://icalendar.org/iCalendar-RFC-5545/3-8-5-1-exception-date-times.html
+func (gc *Gocal) parseExcludeDates(l *Line) (dates, days []time.Time) {
+	for _, v := range strings.Split(l.Value, ",") {
+		d, err := gc.parseTime(v, l.Params, parser.TimeStart, false)
+		if err != nil {
+			gc.warn(SeverityWarning, fmt.Errorf("ignoring unparsable exclusion date: %s", err))
+			continue
+		}
+
+		if l.Params["VALUE"] == "DATE" || len(v) == 8 {
+			days = append(days, *d)
+			continue
+		}
+		dates = append(dates, *d)
+	}
+
+	return dates, days
+}
@@ -0,0 +1,455 @@
+package gocal
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/apognu/gocal/parser"
+)
+
+const (
+	DefaultProdID  = "-//apognu//gocal//EN"
+	DefaultVersion = "2.0"
+)
+
+// Canonical order of recurrence rule parts when serializing a rule.
+var recurrenceRuleParts = []string{"FREQ", "UNTIL", "COUNT", "INTERVAL", "BYSECOND", "BYMINUTE", "BYHOUR", "BYDAY", "BYMONTHDAY", "BYYEARDAY", "BYWEEKNO", "BYMONTH", "BYSETPOS", "WKST"}
+
+// Properties whose value is TEXT, and needs to be escaped. Line breaks are only unescaped in those.
+var textProperties = map[string]bool{"UID": true, "SUMMARY": true, "DESCRIPTION": true, "LOCATION": true, "COMMENT": true, "CONTACT": true, "RELATED-TO": true, "TZNAME": true}
+
+// Encoder writes events as an iCalendar (RFC 5545) feed.
+type Encoder struct {
+	w   *bufio.Writer
+	err error
+
+	ProdID  string
+	Version string
+	Method  string
+
+	// VTIMEZONEs written for the TZIDs used by the events, such as Gocal.Timezones
+	Timezones map[string]*Timezone
+}
+
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{
+		w:       bufio.NewWriter(w),
+		ProdID:  DefaultProdID,
+		Version: DefaultVersion,
+	}
+}
+
+// Encode writes a whole VCALENDAR containing the given events.
+func (enc *Encoder) Encode(events []Event) error {
+	enc.writeLine("BEGIN", nil, "VCALENDAR")
+	enc.writeLine("VERSION", nil, enc.Version)
+	enc.writeLine("PRODID", nil, enc.ProdID)
+	if enc.Method != "" {
+		enc.writeLine("METHOD", nil, enc.Method)
+	}
+
+	for _, tzid := range referencedTimezones(events) {
+		if tz, ok := enc.Timezones[tzid]; ok {
+			enc.encodeTimezone(tz)
+		}
+	}
+
+	for _, e := range events {
+		enc.encodeEvent(e)
+	}
+
+	enc.writeLine("END", nil, "VCALENDAR")
+
+	if enc.err != nil {
+		return enc.err
+	}
+
+	return enc.w.Flush()
+}
+
+func (enc *Encoder) encodeEvent(e Event) {
+	enc.writeLine("BEGIN", nil, "VEVENT")
+	enc.writeLine("UID", nil, parser.EscapeString(e.Uid))
+	enc.writeUTCDate("DTSTAMP", e.Stamp)
+	enc.writeDate("DTSTART", e.Start, e.RawStart, false)
+
+	if e.Duration != nil {
+		enc.writeLine("DURATION", nil, formatDuration(*e.Duration))
+	} else if e.End != nil {
+		raw := e.RawEnd
+		if raw.Value == "" {
+			raw = e.RawStart
+		}
+
+		enc.writeDate("DTEND", e.End, raw, true)
+	}
+
+	enc.writeText("SUMMARY", e.Summary)
+	enc.writeText("DESCRIPTION", e.Description)
+	enc.writeText("LOCATION", e.Location)
+	if e.Geo != nil {
+		enc.writeLine("GEO", nil, fmt.Sprintf("%s;%s", strconv.FormatFloat(e.Geo.Lat, 'f', -1, 64), strconv.FormatFloat(e.Geo.Long, 'f', -1, 64)))
+	}
+	if e.URL != "" {
+		enc.writeLine("URL", nil, e.URL)
+	}
+	enc.writeText("STATUS", e.Status)
+	enc.writeText("CLASS", e.Class)
+	enc.writeText("COMMENT", e.Comment)
+
+	if len(e.Categories) > 0 {
+		categories := make([]string, len(e.Categories))
+		for i, c := range e.Categories {
+			categories[i] = parser.EscapeString(c)
+		}
+
+		enc.writeLine("CATEGORIES", nil, strings.Join(categories, ","))
+	}
+
+	if e.Organizer != nil {
+		enc.writeLine("ORGANIZER", map[string]string{"CN": e.Organizer.Cn, "DIR": e.Organizer.DirectoryDn}, e.Organizer.Value)
+	}
+	for _, a := range e.Attendees {
+		enc.encodeAttendee(a)
+	}
+	for _, a := range e.Attachments {
+		enc.encodeAttachment(a)
+	}
+
+	for _, r := range e.RecurrenceRule {
+		enc.writeLine("RRULE", nil, r.String())
+	}
+	for _, r := range e.ExcludeRules {
+		enc.writeLine("EXRULE", nil, r.String())
+	}
+	enc.writeDate("RECURRENCE-ID", e.RecurrenceID, e.RawRecurrenceID, false)
+	for _, d := range e.RecurrenceDates {
+		if d.End != nil {
+			enc.writeLine("RDATE", map[string]string{"VALUE": "PERIOD"}, d.Start.UTC().Format("20060102T150405Z")+"/"+d.End.UTC().Format("20060102T150405Z"))
+			continue
+		}
+
+		enc.writeRecurrenceDate("RDATE", d.Start, e)
+	}
+	for _, d := range e.ExcludeDates {
+		enc.writeRecurrenceDate("EXDATE", d, e)
+	}
+	for _, d := range e.ExcludeDays {
+		enc.writeLine("EXDATE", map[string]string{"VALUE": "DATE"}, d.Format("20060102"))
+	}
+
+	if e.Sequence != 0 {
+		enc.writeLine("SEQUENCE", nil, strconv.Itoa(e.Sequence))
+	}
+	enc.writeUTCDate("CREATED", e.Created)
+	enc.writeUTCDate("LAST-MODIFIED", e.LastModified)
+
+	enc.writeCustomAttributes(e.CustomAttributes)
+
+	for _, a := range e.Alarms {
+		enc.encodeAlarm(a)
+	}
+
+	enc.writeLine("END", nil, "VEVENT")
+}
+
+// referencedTimezones lists the TZIDs the dates of events are written with, in order of appearance.
+func referencedTimezones(events []Event) []string {
+	tzids := make([]string, 0)
+	seen := make(map[string]bool)
+
+	add := func(raw RawDate, d *time.Time) {
+		if d == nil || raw.Params["VALUE"] == "DATE" || len(raw.Value) == 8 {
+			return
+		}
+
+		// Same as writeDate, dates without a TZID are written with the one of their location
+		tzid := unquoteTZID(raw.Params["TZID"])
+		if tzid == "" && d.Location() != time.UTC && d.Location() != time.Local {
+			tzid = d.Location().String()
+		}
+		if tzid != "" && !seen[tzid] {
+			seen[tzid] = true
+			tzids = append(tzids, tzid)
+		}
+	}
+
+	for _, e := range events {
+		add(e.RawStart, e.Start)
+		add(e.RawEnd, e.End)
+		add(e.RawRecurrenceID, e.RecurrenceID)
+	}
+
+	return tzids
+}
+
+// encodeTimezone writes a VTIMEZONE, with its observances as they were parsed.
+func (enc *Encoder) encodeTimezone(tz *Timezone) {
+	enc.writeLine("BEGIN", nil, "VTIMEZONE")
+	enc.writeLine("TZID", nil, tz.ID)
+
+	for _, o := range tz.Observances {
+		kind := "STANDARD"
+		if o.Daylight {
+			kind = "DAYLIGHT"
+		}
+
+		enc.writeLine("BEGIN", nil, kind)
+		enc.writeLine("DTSTART", nil, o.Start.Format("20060102T150405"))
+		enc.writeLine("TZOFFSETFROM", nil, parser.FormatUTCOffset(o.OffsetFrom))
+		enc.writeLine("TZOFFSETTO", nil, parser.FormatUTCOffset(o.OffsetTo))
+		enc.writeText("TZNAME", o.Name)
+		if o.RecurrenceRule != nil {
+			enc.writeLine("RRULE", nil, o.RecurrenceRule.String())
+		}
+		for _, d := range o.RecurrenceDates {
+			enc.writeLine("RDATE", nil, d.Format("20060102T150405"))
+		}
+		enc.writeLine("END", nil, kind)
+	}
+
+	enc.writeLine("END", nil, "VTIMEZONE")
+}
+
+func (enc *Encoder) encodeAttendee(a Attendee) {
+	params := map[string]string{"CN": a.Cn, "DIR": a.DirectoryDn, "PARTSTAT": a.Status}
+	for k, v := range a.CustomAttributes {
+		params[k] = v
+	}
+
+	enc.writeLine("ATTENDEE", params, a.Value)
+}
+
+func (enc *Encoder) encodeAttachment(a Attachment) {
+	params := map[string]string{"VALUE": a.Type, "ENCODING": a.Encoding, "FMTTYPE": a.Mime, "FILENAME": a.Filename}
+
+	enc.writeLine("ATTACH", params, a.Value)
+}
+
+func (enc *Encoder) encodeAlarm(a Alarm) {
+	enc.writeLine("BEGIN", nil, "VALARM")
+	enc.writeLine("ACTION", nil, a.Action)
+
+	if a.Trigger != nil {
+		if a.Trigger.Time != nil {
+			enc.writeLine("TRIGGER", map[string]string{"VALUE": "DATE-TIME"}, a.Trigger.Time.UTC().Format("20060102T150405Z"))
+		} else if a.Trigger.Duration != nil {
+			params := map[string]string{}
+			if a.Trigger.Related == TriggerRelatedEnd {
+				params["RELATED"] = TriggerRelatedEnd
+			}
+
+			enc.writeLine("TRIGGER", params, formatDuration(*a.Trigger.Duration))
+		}
+	}
+
+	if a.Duration != nil {
+		enc.writeLine("REPEAT", nil, strconv.Itoa(a.Repeat))
+		enc.writeLine("DURATION", nil, formatDuration(*a.Duration))
+	}
+
+	enc.writeText("SUMMARY", a.Summary)
+	enc.writeText("DESCRIPTION", a.Description)
+	for _, at := range a.Attendees {
+		enc.encodeAttendee(at)
+	}
+	for _, at := range a.Attachments {
+		enc.encodeAttachment(at)
+	}
+
+	enc.writeCustomAttributes(a.CustomAttributes)
+
+	enc.writeLine("END", nil, "VALARM")
+}
+
+func (enc *Encoder) writeCustomAttributes(attrs map[string]string) {
+	keys := make([]string, 0, len(attrs))
+	for k := range attrs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		enc.writeLine(k, nil, parser.EscapeString(attrs[k]))
+	}
+}
+
+func (enc *Encoder) writeText(name, value string) {
+	if value == "" {
+		return
+	}
+
+	enc.writeLine(name, nil, parser.EscapeString(value))
+}
+
+func (enc *Encoder) writeUTCDate(name string, d *time.Time) {
+	if d == nil {
+		return
+	}
+
+	enc.writeLine(name, nil, d.UTC().Format("20060102T150405Z"))
+}
+
+// writeRecurrenceDate writes a date in the format of DTSTART, converted to its zone as they share their TZID.
+func (enc *Encoder) writeRecurrenceDate(name string, d time.Time, e Event) {
+	if e.Start != nil {
+		d = d.In(e.Start.Location())
+	}
+
+	enc.writeDate(name, &d, e.RawStart, false)
+}
+
+// writeDate writes a DATE or DATE-TIME value, keeping the value type and TZID from the raw parsed value.
+// DATE end values are parsed as the last instant of the previous day, so they are written back as the next day.
+func (enc *Encoder) writeDate(name string, d *time.Time, raw RawDate, end bool) {
+	if d == nil {
+		return
+	}
+
+	params, value := map[string]string{}, ""
+
+	switch {
+	case raw.Params["VALUE"] == "DATE" || len(raw.Value) == 8:
+		day := *d
+		if end {
+			day = day.Add(-time.Nanosecond).AddDate(0, 0, 1)
+		}
+
+		params["VALUE"], value = "DATE", day.Format("20060102")
+	case raw.Params["TZID"] != "":
+		params["TZID"], value = raw.Params["TZID"], d.Format("20060102T150405")
+	case d.Location() == time.UTC:
+		value = d.Format("20060102T150405Z")
+	case d.Location() == time.Local:
+		value = d.Format("20060102T150405")
+	default:
+		params["TZID"], value = d.Location().String(), d.Format("20060102T150405")
+	}
+
+	// Only set on RECURRENCE-ID
+	if r, ok := raw.Params["RANGE"]; ok {
+		params["RANGE"] = r
+	}
+
+	enc.writeLine(name, params, value)
+}
+
+// writeLine writes a folded content line, skipping empty parameters.
+func (enc *Encoder) writeLine(name string, params map[string]string, value string) {
+	if enc.err != nil {
+		return
+	}
+
+	keys := make([]string, 0, len(params))
+	for k, v := range params {
+		if v != "" {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	var sb strings.Builder
+	sb.WriteString(name)
+	for _, k := range keys {
+		sb.WriteString(";")
+		sb.WriteString(k)
+		sb.WriteString("=")
+		sb.WriteString(quoteParameter(params[k]))
+	}
+	sb.WriteString(":")
+	sb.WriteString(value)
+
+	for _, l := range parser.FoldLine(sb.String()) {
+		if _, err := enc.w.WriteString(l + "\r\n"); err != nil {
+			enc.err = err
+			return
+		}
+	}
+}
+
+// Parameter values containing COLON, SEMICOLON or COMMA must be quoted.
+func quoteParameter(v string) string {
+	if strings.HasPrefix(v, `"`) && strings.HasSuffix(v, `"`) && len(v) > 1 {
+		return v
+	}
+	if strings.ContainsAny(v, ":;,") {
+		return `"` + v + `"`
+	}
+
+	return v
+}
+
+// formatDuration formats a duration as per RFC 5545, only using weeks when the duration is a whole number of them.
+// Reference: https://icalendar.org/iCalendar-RFC-5545/3-3-6-duration.html
+func formatDuration(d time.Duration) string {
+	var sb strings.Builder
+
+	if d < 0 {
+		sb.WriteString("-")
+		d = -d
+	}
+	sb.WriteString("P")
+
+	day := 24 * time.Hour
+	if d >= 7*day && d%(7*day) == 0 {
+		sb.WriteString(fmt.Sprintf("%dW", d/(7*day)))
+		return sb.String()
+	}
+
+	if d >= day {
+		sb.WriteString(fmt.Sprintf("%dD", d/day))
+		d %= day
+
+		if d == 0 {
+			return sb.String()
+		}
+	}
+
+	sb.WriteString("T")
+	if h := d / time.Hour; h > 0 {
+		sb.WriteString(fmt.Sprintf("%dH", h))
+	}
+	if m := d % time.Hour / time.Minute; m > 0 {
+		sb.WriteString(fmt.Sprintf("%dM", m))
+	}
+	if s := d % time.Minute / time.Second; s > 0 || d == 0 {
+		sb.WriteString(fmt.Sprintf("%dS", s))
+	}
+
+	return sb.String()
+}
+
+// EncodeLines writes lines kept in lossless mode. Lines that were not modified are written
+// exactly as they were read, other lines are serialized, escaped and folded.
+func (enc *Encoder) EncodeLines(lines Lines) error {
+	for _, l := range lines {
+		if enc.err != nil {
+			break
+		}
+
+		if !l.IsModified() {
+			if _, err := enc.w.WriteString(l.raw); err != nil {
+				enc.err = err
+			}
+			continue
+		}
+
+		value := l.Value
+		if textProperties[l.Key] || strings.HasPrefix(l.Key, "X-") {
+			value = parser.EscapeString(value)
+		}
+
+		enc.writeLine(l.Key, l.Params, value)
+	}
+
+	if enc.err != nil {
+		return enc.err
+	}
+
+	return enc.w.Flush()
+}
@@ -0,0 +1,295 @@
+package gocal
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const encodeICS = `BEGIN:VCALENDAR
+METHOD:REQUEST
+BEGIN:VEVENT
+DTSTART;VALUE=DATE:20190101
+DTEND;VALUE=DATE:20190103
+DTSTAMP:20151116T133227Z
+UID:0001@example.net
+SUMMARY:All-day event\, with a comma
+DESCRIPTION:A very long description that spans over several lines once folded\, with accents: éèàùç and a new line\nhere.
+LOCATION:My Place
+GEO:32.745;128.45
+CATEGORIES:WORK,MEETING
+ORGANIZER;CN=John Connor:mailto:john.connor@example.net
+ATTENDEE;CN=Antoine Popineau;PARTSTAT=ACCEPTED;X-NUM-GUESTS=0:mailto:antoine.popineau@example.net
+ATTACH;FMTTYPE=text/plain:https://example.com/file.txt
+X-COLOR:#abc123
+END:VEVENT
+BEGIN:VEVENT
+DTSTART;TZID=Europe/Paris:20190101T090000
+DTEND;TZID=Europe/Paris:20190101T110000
+DTSTAMP:20151116T133227Z
+UID:0002@example.net
+SUMMARY:Recurring event
+RRULE:FREQ=WEEKLY;COUNT=3;BYDAY=TU
+SEQUENCE:2
+BEGIN:VALARM
+ACTION:DISPLAY
+TRIGGER:-PT15M
+END:VALARM
+END:VEVENT
+BEGIN:VEVENT
+DTSTART:20190101T090000Z
+DURATION:PT1H30M
+DTSTAMP:20151116T133227Z
+UID:0003@example.net
+SUMMARY:Event with duration
+END:VEVENT
+END:VCALENDAR`
+
+func parseForEncoding(t *testing.T, ics string) *Gocal {
+	start, end := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2019, 1, 2, 0, 0, 0, 0, time.UTC)
+
+	gc := NewParser(strings.NewReader(ics))
+	gc.Start, gc.End = &start, &end
+	err := gc.Parse()
+
+	assert.Nil(t, err)
+
+	return gc
+}
+
+func Test_EncodeRoundTrip(t *testing.T) {
+	gc := parseForEncoding(t, encodeICS)
+	assert.Len(t, gc.Events, 3)
+
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf)
+	enc.Method = gc.Method
+	err := enc.Encode(gc.Events)
+
+	assert.Nil(t, err)
+
+	for _, l := range strings.Split(strings.TrimSuffix(buf.String(), "\r\n"), "\r\n") {
+		assert.True(t, len(l) <= 75, l)
+	}
+
+	out := parseForEncoding(t, buf.String())
+
+	assert.Equal(t, "REQUEST", out.Method)
+	assert.Len(t, out.Events, len(gc.Events))
+
+	for i := range gc.Events {
+		exp, got := gc.Events[i], out.Events[i]
+
+		assert.Equal(t, exp.Uid, got.Uid)
+		assert.Equal(t, exp.Summary, got.Summary)
+		assert.Equal(t, exp.Description, got.Description)
+		assert.Equal(t, exp.Location, got.Location)
+		assert.Equal(t, exp.Geo, got.Geo)
+		assert.Equal(t, exp.Categories, got.Categories)
+		assert.Equal(t, exp.Organizer, got.Organizer)
+		assert.Equal(t, exp.Attendees, got.Attendees)
+		assert.Equal(t, exp.Attachments, got.Attachments)
+		assert.Equal(t, exp.RecurrenceRule, got.RecurrenceRule)
+		assert.Equal(t, exp.CustomAttributes, got.CustomAttributes)
+		assert.Equal(t, exp.Alarms, got.Alarms)
+		assert.Equal(t, exp.Duration, got.Duration)
+		assert.True(t, exp.Start.Equal(*got.Start))
+		assert.True(t, exp.End.Equal(*got.End))
+		assert.Equal(t, exp.Start.Location().String(), got.Start.Location().String())
+		assert.Equal(t, exp.RawStart.Params, got.RawStart.Params)
+	}
+}
+
+func Test_EncodeFormat(t *testing.T) {
+	var buf bytes.Buffer
+
+	start := time.Date(2019, 1, 1, 9, 0, 0, 0, time.UTC)
+	end := start.Add(time.Hour)
+
+	err := NewEncoder(&buf).Encode([]Event{{Uid: "one@gocal", Start: &start, End: &end, Summary: "Hello; world"}})
+
+	assert.Nil(t, err)
+	assert.Equal(t, "BEGIN:VCALENDAR\r\n"+
+		"VERSION:2.0\r\n"+
+		"PRODID:-//apognu//gocal//EN\r\n"+
+		"BEGIN:VEVENT\r\n"+
+		"UID:one@gocal\r\n"+
+		"DTSTART:20190101T090000Z\r\n"+
+		"DTEND:20190101T100000Z\r\n"+
+		"SUMMARY:Hello\\; world\r\n"+
+		"END:VEVENT\r\n"+
+		"END:VCALENDAR\r\n", buf.String())
+}
+
+func Test_FormatDuration(t *testing.T) {
+	data := map[time.Duration]string{
+		0:                               "PT0S",
+		15 * time.Minute:                "PT15M",
+		-15 * time.Minute:               "-PT15M",
+		90 * time.Minute:                "PT1H30M",
+		24 * time.Hour:                  "P1D",
+		26*time.Hour + 10*time.Second:   "P1DT2H10S",
+		14 * 24 * time.Hour:             "P2W",
+		-7 * 24 * time.Hour:             "-P1W",
+		8*24*time.Hour + 30*time.Minute: "P8DT30M",
+	}
+
+	for in, exp := range data {
+		assert.Equal(t, exp, formatDuration(in))
+	}
+}
+
+func Test_EncodeRecurrenceDates(t *testing.T) {
+	var buf bytes.Buffer
+
+	start := time.Date(2019, 1, 1, 9, 0, 0, 0, time.UTC)
+	end := start.Add(time.Hour)
+	extra, periodEnd := start.AddDate(0, 0, 2), start.AddDate(0, 0, 2).Add(30*time.Minute)
+
+	err := NewEncoder(&buf).Encode([]Event{{
+		Uid:             "rdate@gocal",
+		Start:           &start,
+		End:             &end,
+		IsRecurring:     true,
+		RecurrenceDates: []RecurrenceDate{{Start: start.AddDate(0, 0, 1)}, {Start: extra, End: &periodEnd}},
+	}})
+
+	assert.Nil(t, err)
+	assert.Contains(t, buf.String(), "RDATE:20190102T090000Z\r\n")
+	assert.Contains(t, buf.String(), "RDATE;VALUE=PERIOD:20190103T090000Z/20190103T093000Z\r\n")
+}
+
+func Test_EncodeRecurrenceID(t *testing.T) {
+	var buf bytes.Buffer
+
+	paris, _ := time.LoadLocation("Europe/Paris")
+	start := time.Date(2019, 1, 4, 10, 0, 0, 0, paris)
+	end := start.Add(time.Hour)
+	rid := time.Date(2019, 1, 4, 9, 0, 0, 0, paris)
+
+	err := NewEncoder(&buf).Encode([]Event{{
+		Uid:             "override@gocal",
+		Start:           &start,
+		End:             &end,
+		RecurrenceID:    &rid,
+		RawRecurrenceID: RawDate{Value: "20190104T090000", Params: map[string]string{"TZID": "Europe/Paris", "RANGE": "THISANDFUTURE"}},
+	}})
+
+	assert.Nil(t, err)
+	assert.Contains(t, buf.String(), "RECURRENCE-ID;RANGE=THISANDFUTURE;TZID=Europe/Paris:20190104T090000\r\n")
+}
+
+func Test_EncodeTimezones(t *testing.T) {
+	ics := `BEGIN:VCALENDAR
+BEGIN:VTIMEZONE
+TZID:W. Europe Standard Time
+BEGIN:STANDARD
+DTSTART:16010101T030000
+TZOFFSETFROM:+0200
+TZOFFSETTO:+0100
+RRULE:FREQ=YEARLY;BYDAY=-1SU;BYMONTH=10
+END:STANDARD
+BEGIN:DAYLIGHT
+DTSTART:16010101T020000
+TZOFFSETFROM:+0100
+TZOFFSETTO:+0200
+TZNAME:CEST
+RRULE:FREQ=YEARLY;BYDAY=-1SU;BYMONTH=3
+END:DAYLIGHT
+END:VTIMEZONE
+BEGIN:VTIMEZONE
+TZID:Unused
+BEGIN:STANDARD
+DTSTART:19700101T000000
+TZOFFSETFROM:+0530
+TZOFFSETTO:+0530
+END:STANDARD
+END:VTIMEZONE
+BEGIN:VEVENT
+UID:summer@gocal
+DTSTAMP:20151116T133227Z
+DTSTART;TZID=W. Europe Standard Time:20240715T090000
+DTEND;TZID=W. Europe Standard Time:20240715T100000
+END:VEVENT
+END:VCALENDAR`
+
+	start, end := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 12, 31, 0, 0, 0, 0, time.UTC)
+
+	gc := NewParser(strings.NewReader(ics))
+	gc.Start, gc.End = &start, &end
+	err := gc.Parse()
+
+	assert.Nil(t, err)
+
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf)
+	enc.Timezones = gc.Timezones
+	err = enc.Encode(gc.Events)
+
+	assert.Nil(t, err)
+	assert.Contains(t, buf.String(), "BEGIN:VTIMEZONE\r\nTZID:W. Europe Standard Time\r\nBEGIN:STANDARD\r\nDTSTART:16010101T030000\r\nTZOFFSETFROM:+0200\r\nTZOFFSETTO:+0100\r\n")
+	assert.Contains(t, buf.String(), "TZNAME:CEST\r\nRRULE:FREQ=YEARLY;BYDAY=-1SU;BYMONTH=3\r\n")
+	assert.NotContains(t, buf.String(), "TZID:Unused")
+
+	// Only used timezones are written, and they resolve the TZID of the events again
+	out := NewParser(strings.NewReader(buf.String()))
+	out.Start, out.End = &start, &end
+	err = out.Parse()
+
+	assert.Nil(t, err)
+	assert.Empty(t, out.Warnings)
+	assert.Len(t, out.Events, 1)
+	assert.Equal(t, time.Date(2024, 7, 15, 7, 0, 0, 0, time.UTC), out.Events[0].Start.UTC())
+	assert.Equal(t, "W. Europe Standard Time", out.Events[0].Start.Location().String())
+}
+
+func Test_EncodeMixedZoneDates(t *testing.T) {
+	ics := `BEGIN:VCALENDAR
+BEGIN:VEVENT
+UID:mixed@gocal
+DTSTAMP:20151116T133227Z
+DTSTART;TZID=Europe/Paris:20190101T090000
+DTEND;TZID=Europe/Paris:20190101T100000
+RRULE:FREQ=WEEKLY;COUNT=4
+EXDATE:20190108T080000Z
+RDATE:20190110T130000Z
+END:VEVENT
+END:VCALENDAR`
+
+	start, end := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2019, 2, 1, 0, 0, 0, 0, time.UTC)
+
+	gc := NewParser(strings.NewReader(ics))
+	gc.Start, gc.End = &start, &end
+	gc.Recurrence.Mode = RecurrenceModeMasters
+	err := gc.Parse()
+
+	assert.Nil(t, err)
+
+	// Values in another zone than DTSTART are converted to it, as they are written with its TZID
+	var buf bytes.Buffer
+	err = NewEncoder(&buf).Encode(gc.Events)
+
+	assert.Nil(t, err)
+	assert.Contains(t, buf.String(), "EXDATE;TZID=Europe/Paris:20190108T090000\r\n")
+	assert.Contains(t, buf.String(), "RDATE;TZID=Europe/Paris:20190110T140000\r\n")
+
+	out := NewParser(strings.NewReader(buf.String()))
+	out.Start, out.End = &start, &end
+	err = out.Parse()
+
+	assert.Nil(t, err)
+
+	starts := make([]time.Time, 0)
+	for _, e := range out.Events {
+		starts = append(starts, e.Start.UTC())
+	}
+	assert.Equal(t, []time.Time{
+		time.Date(2019, 1, 1, 8, 0, 0, 0, time.UTC),
+		time.Date(2019, 1, 10, 13, 0, 0, 0, time.UTC),
+		time.Date(2019, 1, 15, 8, 0, 0, 0, time.UTC),
+		time.Date(2019, 1, 22, 8, 0, 0, 0, time.UTC),
+	}, starts)
+}
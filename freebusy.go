@@ -0,0 +1,118 @@
+package gocal
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+func (gc *Gocal) parseFreeBusy(l *Line) error {
+	// If this is nil, that means we did not get a BEGIN:VFREEBUSY
+	if gc.freeBusyBuffer == nil {
+		return nil
+	}
+
+	switch l.Key {
+	case "UID":
+		if err := resolve(gc, l, &gc.freeBusyBuffer.Uid, resolveString, nil); err != nil {
+			return err
+		}
+	case "DTSTAMP":
+		if err := resolve(gc, l, &gc.freeBusyBuffer.Stamp, resolveDate, nil); err != nil {
+			return err
+		}
+	case "DTSTART":
+		if err := resolve(gc, l, &gc.freeBusyBuffer.Start, resolveDate, nil); err != nil {
+			return err
+		}
+	case "DTEND":
+		if err := resolve(gc, l, &gc.freeBusyBuffer.End, resolveDate, nil); err != nil {
+			return err
+		}
+	case "ORGANIZER":
+		if err := resolve(gc, l, &gc.freeBusyBuffer.Organizer, resolveOrganizer, nil); err != nil {
+			return err
+		}
+	case "ATTENDEE":
+		gc.freeBusyBuffer.Attendees = append(gc.freeBusyBuffer.Attendees, parseAttendee(l))
+	case "FREEBUSY":
+		// Reference: https://icalendar.org/iCalendar-RFC-5545/3-8-2-6-free-busy-time.html
+		ty := strings.ToUpper(l.Params["FBTYPE"])
+		if ty == "" {
+			ty = FreeBusyTypeBusy
+		}
+
+		for _, v := range strings.Split(l.Value, ",") {
+			start, end, err := gc.parsePeriod(v, l.Params)
+			if err != nil {
+				return err
+			}
+
+			gc.freeBusyBuffer.Periods = append(gc.freeBusyBuffer.Periods, FreeBusyPeriod{Type: ty, Start: *start, End: *end})
+		}
+	case "URL":
+		gc.freeBusyBuffer.URL = l.Value
+	case "COMMENT":
+		gc.freeBusyBuffer.Comment = l.Value
+	default:
+		key := strings.ToUpper(l.Key)
+		if strings.HasPrefix(key, "X-") {
+			if gc.freeBusyBuffer.CustomAttributes == nil {
+				gc.freeBusyBuffer.CustomAttributes = make(map[string]string)
+			}
+			gc.freeBusyBuffer.CustomAttributes[key] = l.Value
+		}
+	}
+
+	return nil
+}
+
+func (gc *Gocal) finalizeFreeBusy() error {
+	if err := gc.checkFreeBusy(); err != nil {
+		switch gc.Strict.Mode {
+		case StrictModeFailFeed:
+			return err
+		case StrictModeFailEvent:
+			gc.warn(SeverityError, err)
+			return nil
+		}
+		gc.warn(SeverityError, err)
+	}
+
+	if gc.Strict.Mode == StrictModeFailEvent && !gc.freeBusyBuffer.Valid {
+		return nil
+	}
+
+	gc.FreeBusy = append(gc.FreeBusy, *gc.freeBusyBuffer)
+
+	return nil
+}
+
+func (gc *Gocal) checkFreeBusy() error {
+	if gc.freeBusyBuffer.Uid == "" {
+		gc.freeBusyBuffer.Valid = false
+		return fmt.Errorf("could not parse free/busy without UID")
+	}
+	if gc.freeBusyBuffer.Stamp == nil {
+		gc.freeBusyBuffer.Valid = false
+		return fmt.Errorf("could not parse free/busy without DTSTAMP")
+	}
+
+	return nil
+}
+
+// Busy returns the periods during which the calendar user is busy, whatever the reason.
+func (fb FreeBusy) Busy() []FreeBusyPeriod {
+	periods := make([]FreeBusyPeriod, 0)
+	for _, p := range fb.Periods {
+		if p.Type != FreeBusyTypeFree {
+			periods = append(periods, p)
+		}
+	}
+
+	return periods
+}
+
+func (p FreeBusyPeriod) Duration() time.Duration {
+	return p.End.Sub(p.Start)
+}
@@ -12,9 +12,12 @@ import (
 )
 
 func NewParser(r io.Reader) *Gocal {
-	return &Gocal{
-		scanner: bufio.NewScanner(r),
-		Events:  make([]Event, 0),
+	gc := &Gocal{
+		scanner:  bufio.NewScanner(r),
+		Events:   make([]Event, 0),
+		Todos:    make([]Todo, 0),
+		Journals: make([]Journal, 0),
+		FreeBusy: make([]FreeBusy, 0),
 		Strict: StrictParams{
 			Mode: StrictModeFailFeed,
 		},
@@ -24,9 +27,72 @@ func NewParser(r io.Reader) *Gocal {
 		SkipBounds:     false,
 		AllDayEventsTZ: time.UTC,
 	}
+
+	gc.scanner.Split(gc.scanLines)
+
+	return gc
 }
 
 func (gc *Gocal) Parse() error {
+	gc.begin()
+
+	for {
+		done, err := gc.parseNext()
+		if err != nil {
+			return err
+		}
+		if done {
+			break
+		}
+	}
+
+	return gc.end()
+}
+
+// Next returns the next event of the feed, parsing the feed as it goes instead of accumulating
+// events in Gocal.Events. It returns io.EOF when there are no more events.
+//
+// Events are returned as soon as their END:VEVENT is reached. The instances of a recurring event are
+// returned once another event that does not override it follows, so overrides listed right after their
+// recurring event still replace its instances. Other overrides (with a RECURRENCE-ID) are held back until
+// the end of the feed, along with the instances of the recurring events they refer to, to be reconciled.
+// Overrides found after the instances of their recurring event were returned are returned as they are,
+// including cancelled ones. Every recurring event is kept as well, to check overrides against it.
+// Events using a TZID whose VTIMEZONE comes later in the feed are held back until it is parsed.
+// Todos, journals and free/busy components are still accumulated.
+func (gc *Gocal) Next() (*Event, error) {
+	gc.streaming = true
+	gc.begin()
+
+	for len(gc.queue) == 0 {
+		if gc.err != nil {
+			return nil, gc.err
+		}
+		if gc.ended {
+			return nil, io.EOF
+		}
+
+		done, err := gc.parseNext()
+		if err != nil {
+			gc.err = err
+			continue
+		}
+		if done {
+			gc.err = gc.end()
+		}
+	}
+
+	e := gc.queue[0]
+	gc.queue = gc.queue[1:]
+
+	return &e, nil
+}
+
+func (gc *Gocal) begin() {
+	if gc.ctx != nil {
+		return
+	}
+
 	if gc.Start == nil {
 		start := time.Now().Add(-1 * 24 * time.Hour)
 		gc.Start = &start
@@ -36,139 +102,511 @@ func (gc *Gocal) Parse() error {
 		gc.End = &end
 	}
 
-	gc.scanner.Scan()
+	gc.scan()
 
-	rInstances := make([]Event, 0)
-	ctx := &Context{Value: ContextRoot}
-	for {
-		l, err, done := gc.parseLine()
-		if err != nil {
-			if done {
-				break
+	gc.recurringInstances = make([]Event, 0)
+	gc.ctx = &Context{Value: ContextRoot}
+}
+
+// reconcile replaces the instances of recurring events by their overrides, and adds the overrides of the given UIDs
+// that moved an instance into the range from outside of it.
+func (gc *Gocal) reconcile(instances []Event, uids []string, from, to time.Time) []Event {
+	ev := make([]Event, 0, len(instances))
+
+	emitted := make(map[*Event]bool)
+	for _, i := range instances {
+		idx := gc.overrides[i.Uid]
+		if idx == nil {
+			if isInRange(i, from, to) {
+				ev = append(ev, i)
 			}
 			continue
 		}
 
-		if l.IsValue("VCALENDAR") {
+		// An overridden instance is replaced in place, cancelled ones are removed
+		if o, ok := idx.exact[i.Start.UnixNano()]; ok {
+			emitted[o] = true
+			if o.Status != StatusCancelled && isInRange(*o, from, to) {
+				ev = append(ev, *o)
+			}
 			continue
 		}
 
-		if ctx.Value == ContextRoot && l.Is("BEGIN", "VEVENT") {
-			ctx = ctx.Nest(ContextEvent)
-
-			gc.buffer = &Event{Valid: true, delayed: make([]*Line, 0)}
-		} else if ctx.Value == ContextRoot && l.IsKey("METHOD") {
-			gc.Method = l.Value
-		} else if ctx.Value == ContextEvent && l.Is("END", "VEVENT") {
-			if ctx.Previous == nil {
-				return fmt.Errorf("got an END:* without matching BEGIN:*")
+		// Instances following a THISANDFUTURE override take its values, and are moved as much as it was
+		if o := idx.future(*i.Start); o != nil {
+			if o.Status == StatusCancelled {
+				continue
 			}
-			ctx = ctx.Previous
+			i = applyOverride(*o, i)
+		}
 
-			for _, d := range gc.buffer.delayed {
-				gc.parseEvent(d)
-			}
+		if isInRange(i, from, to) {
+			ev = append(ev, i)
+		}
+	}
 
-			// Some tools return single full day events as inclusive (same DTSTART
-			// and DTEND) which goes against RFC. Standard tools still handle those
-			// as events spanning 24 hours.
-			if gc.buffer.RawStart.Value == gc.buffer.RawEnd.Value {
-				if value, ok := gc.buffer.RawEnd.Params["VALUE"]; ok && value == "DATE" {
-					gc.buffer.End, err = parser.ParseTime(gc.buffer.RawEnd.Value, gc.buffer.RawEnd.Params, parser.TimeEnd, true, gc.AllDayEventsTZ)
-				}
+	for _, uid := range uids {
+		idx, ok := gc.overrides[uid]
+		if !ok {
+			continue
+		}
+
+		for _, o := range idx.all {
+			if !emitted[o] && o.Status != StatusCancelled && isInRange(*o, from, to) {
+				ev = append(ev, *o)
 			}
+		}
+	}
+
+	return ev
+}
+
+// end reconciles the instances of recurring events with their overrides, once all of them are known.
+func (gc *Gocal) end() error {
+	gc.ended = true
+
+	// Components still missing a VTIMEZONE fall back to UTC now that all of them are known
+	if err := gc.replayDeferred(); err != nil {
+		return err
+	}
+
+	gc.releaseHeld()
 
-			// If an event has a VALUE=DATE start date and no end date, event lasts a day
-			if gc.buffer.End == nil && gc.buffer.RawStart.Params["VALUE"] == "DATE" {
-				d := (*gc.buffer.Start).Add(24 * time.Hour)
+	masters := gc.Recurrence.Mode == RecurrenceModeMasters
 
-				gc.buffer.End = &d
+	// Overrides without a recurring event in the feed are kept as standalone events, and the ones
+	// whose RECURRENCE-ID is not an instance of their recurring event (removed by EXDATE, etc.) are dropped.
+	// Along with unexpanded recurring events, the others are returned as they are, unless cancelled.
+	for _, o := range gc.pendingOverrides {
+		// The instances of its recurring event were already returned, so the override is returned as it is
+		if gc.released[o.Uid] {
+			if gc.SkipBounds || gc.IsInRange(o) {
+				gc.emitEvent(o)
 			}
+			continue
+		}
 
-			if err := gc.checkEvent(); err != nil {
-				switch gc.Strict.Mode {
-				case StrictModeFailFeed:
-					return fmt.Errorf("gocal error: %s", err)
-				case StrictModeFailEvent:
-					continue
-				}
+		master, ok := gc.masters[o.Uid]
+
+		instance := ok && len(master.RecurrenceSet().Between(*o.RecurrenceID, *o.RecurrenceID)) > 0
+		if instance {
+			gc.recordOverride(o)
+		}
+
+		if o.Status != StatusCancelled && (!ok || (masters && instance)) && (gc.SkipBounds || gc.IsInRange(o)) {
+			gc.emitEvent(o)
+		}
+	}
+	gc.pendingOverrides = nil
+
+	if !masters {
+		for _, e := range gc.reconcile(gc.recurringInstances, gc.overriddenUids, *gc.Start, *gc.End) {
+			gc.emitEvent(e)
+		}
+	}
+	gc.recurringInstances = nil
+
+	// Keep whatever could not be parsed at the end of the feed
+	if gc.Lossless && gc.pending != "" {
+		gc.Lines = append(gc.Lines, newRawLine(&Line{}, gc.pending))
+		gc.pending = ""
+	}
+
+	return nil
+}
+
+// parseNext parses and processes a single content line, and reports if the end of the feed was reached.
+func (gc *Gocal) parseNext() (bool, error) {
+	l, err, done := gc.parseLine()
+	if err != nil {
+		if gc.component != nil {
+			gc.lineWarnings = append(gc.lineWarnings, gc.Warnings[len(gc.Warnings)-1])
+		}
+		return done, nil
+	}
+
+	if gc.Lossless {
+		gc.Lines = append(gc.Lines, l)
+	}
+
+	if err := gc.processLine(l); err != nil {
+		return false, err
+	}
+
+	return done, nil
+}
+
+// processLine processes a single content line in the current context.
+func (gc *Gocal) processLine(l *Line) error {
+	gc.line = l
+
+	if gc.Lossless && gc.buffer != nil && gc.ctx.Within(ContextEvent) {
+		gc.buffer.Lines = append(gc.buffer.Lines, l)
+	}
+
+	if l.IsValue("VCALENDAR") {
+		return nil
+	}
+
+	if gc.ctx.Value == ContextRoot && l.IsKey("BEGIN") && deferrableComponents[l.Value] {
+		gc.component = []*Line{l}
+		gc.componentWarnings = len(gc.Warnings)
+		gc.lineWarnings = nil
+		gc.missingTimezones = nil
+	} else if gc.component != nil {
+		gc.component = append(gc.component, l)
+
+		if l.IsKey("END") && gc.ctx.Previous != nil && gc.ctx.Previous.Value == ContextRoot {
+			component := gc.component
+			gc.component = nil
+
+			// Components using a TZID from a VTIMEZONE that was not parsed yet are set aside until it is
+			if gc.missingTimezones != nil {
+				gc.ctx = gc.ctx.Previous
+				gc.deferComponent(component)
+				return nil
 			}
+		}
+	}
 
-			if gc.buffer.Start == nil || gc.buffer.End == nil {
-				continue
+	if gc.ctx.Value == ContextRoot && l.Is("BEGIN", "VEVENT") {
+		gc.ctx = gc.ctx.Nest(ContextEvent)
+
+		gc.buffer = &Event{Valid: true, delayed: make([]*Line, 0)}
+		if gc.Lossless {
+			gc.buffer.Lines = Lines{l}
+		}
+	} else if gc.ctx.Value == ContextEvent && l.Is("BEGIN", "VALARM") {
+		gc.ctx = gc.ctx.Nest(ContextAlarm)
+
+		gc.buffer.Alarms = append(gc.buffer.Alarms, Alarm{})
+	} else if gc.ctx.Value == ContextAlarm && l.Is("END", "VALARM") {
+		gc.ctx = gc.ctx.Previous
+
+		if err := gc.checkAlarm(); err != nil {
+			gc.buffer.Alarms = gc.buffer.Alarms[:len(gc.buffer.Alarms)-1]
+
+			if gc.Strict.Mode == StrictModeFailFeed {
+				return gc.newParseError(l, err)
 			}
+			gc.buffer.Valid = false
+			gc.warn(SeverityError, err)
+		}
+	} else if gc.ctx.Value == ContextRoot && l.Is("BEGIN", "VTODO") {
+		gc.ctx = gc.ctx.Nest(ContextTodo)
 
-			if gc.buffer.IsRecurring {
-				rInstances = append(rInstances, gc.ExpandRecurringEvent(gc.buffer)...)
-			} else {
-				if gc.buffer.End == nil || gc.buffer.Start == nil {
-					continue
-				}
-				if !gc.SkipBounds && !gc.IsInRange(*gc.buffer) {
-					continue
-				}
-				if gc.Strict.Mode == StrictModeFailEvent && !gc.buffer.Valid {
-					continue
-				}
+		gc.todoBuffer = &Todo{Valid: true, delayed: make([]*Line, 0)}
+	} else if gc.ctx.Value == ContextTodo && l.Is("END", "VTODO") {
+		gc.ctx = gc.ctx.Previous
 
-				gc.Events = append(gc.Events, *gc.buffer)
+		if err := gc.finalizeTodo(); err != nil {
+			return gc.newParseError(l, err)
+		}
+	} else if gc.ctx.Value == ContextRoot && l.Is("BEGIN", "VJOURNAL") {
+		gc.ctx = gc.ctx.Nest(ContextJournal)
+
+		gc.journalBuffer = &Journal{Valid: true, Descriptions: make([]string, 0)}
+	} else if gc.ctx.Value == ContextJournal && l.Is("END", "VJOURNAL") {
+		gc.ctx = gc.ctx.Previous
+
+		if err := gc.finalizeJournal(); err != nil {
+			return gc.newParseError(l, err)
+		}
+	} else if gc.ctx.Value == ContextRoot && l.Is("BEGIN", "VFREEBUSY") {
+		gc.ctx = gc.ctx.Nest(ContextFreeBusy)
+
+		gc.freeBusyBuffer = &FreeBusy{Valid: true, Periods: make([]FreeBusyPeriod, 0)}
+	} else if gc.ctx.Value == ContextFreeBusy && l.Is("END", "VFREEBUSY") {
+		gc.ctx = gc.ctx.Previous
+
+		if err := gc.finalizeFreeBusy(); err != nil {
+			return gc.newParseError(l, err)
+		}
+	} else if gc.ctx.Value == ContextRoot && l.Is("BEGIN", "VTIMEZONE") {
+		gc.ctx = gc.ctx.Nest(ContextTimezone)
+
+		gc.tzBuffer = &Timezone{Observances: make([]TimezoneObservance, 0)}
+	} else if gc.ctx.Value == ContextTimezone && (l.Is("BEGIN", "STANDARD") || l.Is("BEGIN", "DAYLIGHT")) {
+		gc.ctx = gc.ctx.Nest(ContextTimezoneObservance)
+
+		gc.tzBuffer.Observances = append(gc.tzBuffer.Observances, TimezoneObservance{Daylight: l.IsValue("DAYLIGHT")})
+	} else if gc.ctx.Value == ContextTimezoneObservance && (l.Is("END", "STANDARD") || l.Is("END", "DAYLIGHT")) {
+		gc.ctx = gc.ctx.Previous
+
+		if err := gc.finalizeTimezoneObservance(); err != nil {
+			if gc.Strict.Mode == StrictModeFailFeed {
+				return gc.newParseError(l, err)
 			}
-		} else if l.IsKey("BEGIN") {
-			ctx = ctx.Nest(ContextUnknown)
-		} else if l.IsKey("END") {
-			if ctx.Previous == nil {
-				return fmt.Errorf("got an END:%s without matching BEGIN:%s", l.Value, l.Value)
+			gc.warn(SeverityError, err)
+		}
+	} else if gc.ctx.Value == ContextTimezone && l.Is("END", "VTIMEZONE") {
+		gc.ctx = gc.ctx.Previous
+
+		if err := gc.finalizeTimezone(); err != nil {
+			if gc.Strict.Mode == StrictModeFailFeed {
+				return gc.newParseError(l, err)
 			}
-			ctx = ctx.Previous
-		} else if ctx.Value == ContextEvent {
-			if err := gc.parseEvent(l); err != nil {
-				if _, ok := err.(DuplicateAttributeError); ok {
-					switch gc.Duplicate.Mode {
-					case DuplicateModeFailStrict:
-						switch gc.Strict.Mode {
-						case StrictModeFailFeed:
-							return fmt.Errorf("gocal error: %s", err)
-						case StrictModeFailEvent:
-							gc.buffer.Valid = false
-							continue
-						case StrictModeFailAttribute:
-							gc.buffer.Valid = false
-							continue
-						}
-					}
-				}
+			gc.warn(SeverityError, err)
+		} else if err := gc.replayDeferred(); err != nil {
+			return err
+		}
+	} else if gc.ctx.Value == ContextRoot && l.IsKey("METHOD") {
+		gc.Method = l.Value
+	} else if gc.ctx.Value == ContextEvent && l.Is("END", "VEVENT") {
+		if gc.ctx.Previous == nil {
+			return gc.newParseError(l, fmt.Errorf("got an END:* without matching BEGIN:*"))
+		}
+		gc.ctx = gc.ctx.Previous
 
-				return fmt.Errorf(fmt.Sprintf("gocal error: %s", err))
+		if err := gc.finalizeEvent(); err != nil {
+			return gc.newParseError(l, err)
+		}
+	} else if l.IsKey("BEGIN") {
+		gc.ctx = gc.ctx.Nest(ContextUnknown)
+	} else if l.IsKey("END") {
+		if gc.ctx.Previous == nil {
+			return gc.newParseError(l, fmt.Errorf("got an END:%s without matching BEGIN:%s", l.Value, l.Value))
+		}
+		gc.ctx = gc.ctx.Previous
+	} else if gc.ctx.Value == ContextTimezone {
+		gc.parseTimezone(l)
+	} else if gc.ctx.Value == ContextTimezoneObservance {
+		if err := gc.parseTimezoneObservance(l); err != nil {
+			if gc.Strict.Mode == StrictModeFailFeed {
+				return gc.newParseError(l, err)
+			}
+			gc.warn(SeverityError, err)
+		}
+	} else if gc.ctx.Value == ContextEvent {
+		if err := gc.parseEvent(l); err != nil {
+			if err := gc.handleAttributeError(err, &gc.buffer.Valid); err != nil {
+				return gc.newParseError(l, err)
+			}
+		}
+	} else if gc.ctx.Value == ContextAlarm {
+		if err := gc.parseAlarm(l); err != nil {
+			if err := gc.handleAttributeError(err, &gc.buffer.Valid); err != nil {
+				return gc.newParseError(l, err)
+			}
+		}
+	} else if gc.ctx.Value == ContextTodo {
+		if err := gc.parseTodo(l); err != nil {
+			if err := gc.handleAttributeError(err, &gc.todoBuffer.Valid); err != nil {
+				return gc.newParseError(l, err)
+			}
+		}
+	} else if gc.ctx.Value == ContextJournal {
+		if err := gc.parseJournal(l); err != nil {
+			if err := gc.handleAttributeError(err, &gc.journalBuffer.Valid); err != nil {
+				return gc.newParseError(l, err)
+			}
+		}
+	} else if gc.ctx.Value == ContextFreeBusy {
+		if err := gc.parseFreeBusy(l); err != nil {
+			if err := gc.handleAttributeError(err, &gc.freeBusyBuffer.Valid); err != nil {
+				return gc.newParseError(l, err)
 			}
-		} else {
-			continue
 		}
+	}
 
-		if done {
-			break
+	return nil
+}
+
+// newParseError wraps an error with the position and component of the line that caused it.
+func (gc *Gocal) newParseError(l *Line, err error) *ParseError {
+	perr := &ParseError{Line: l.number, LogicalLine: l.logicalNumber, Err: err}
+
+	if l.IsKey("BEGIN") || l.IsKey("END") {
+		perr.Component = l.Value
+	} else {
+		perr.Component = contextNames[gc.ctx.Value]
+		perr.Property = l.Key
+	}
+
+	switch perr.Component {
+	case "VEVENT", "VALARM":
+		if gc.buffer != nil {
+			perr.Uid = gc.buffer.Uid
+		}
+	case "VTODO":
+		if gc.todoBuffer != nil {
+			perr.Uid = gc.todoBuffer.Uid
+		}
+	case "VJOURNAL":
+		if gc.journalBuffer != nil {
+			perr.Uid = gc.journalBuffer.Uid
+		}
+	case "VFREEBUSY":
+		if gc.freeBusyBuffer != nil {
+			perr.Uid = gc.freeBusyBuffer.Uid
+		}
+	}
+
+	return perr
+}
+
+// warn records a recovered problem against the line being processed.
+func (gc *Gocal) warn(severity int, err error) {
+	if gc.line == nil {
+		return
+	}
+
+	gc.Warnings = append(gc.Warnings, Diagnostic{ParseError: *gc.newParseError(gc.line, err), Severity: severity})
+}
+
+func (gc *Gocal) finalizeEvent() error {
+	for _, d := range gc.buffer.delayed {
+		gc.parseEvent(d)
+	}
+
+	// Some tools return single full day events as inclusive (same DTSTART
+	// and DTEND) which goes against RFC. Standard tools still handle those
+	// as events spanning 24 hours.
+	if gc.buffer.RawStart.Value == gc.buffer.RawEnd.Value {
+		if value, ok := gc.buffer.RawEnd.Params["VALUE"]; ok && value == "DATE" {
+			gc.buffer.End, _ = gc.parseTime(gc.buffer.RawEnd.Value, gc.buffer.RawEnd.Params, parser.TimeEnd, true)
 		}
 	}
 
-	for _, i := range rInstances {
-		if !gc.IsRecurringInstanceOverriden(&i) && gc.IsInRange(i) {
-			gc.Events = append(gc.Events, i)
+	// If an event has a VALUE=DATE start date and no end date, event lasts a day
+	if gc.buffer.End == nil && gc.buffer.RawStart.Params["VALUE"] == "DATE" {
+		d := (*gc.buffer.Start).Add(24 * time.Hour)
+
+		gc.buffer.End = &d
+	}
+
+	if err := gc.checkEvent(); err != nil {
+		switch gc.Strict.Mode {
+		case StrictModeFailFeed:
+			return err
+		case StrictModeFailEvent:
+			gc.warn(SeverityError, err)
+			return nil
 		}
+		gc.warn(SeverityError, err)
+	}
+
+	if gc.Strict.Mode == StrictModeFailEvent && !gc.buffer.Valid {
+		return nil
 	}
 
+	if gc.buffer.Start == nil || gc.buffer.End == nil {
+		return nil
+	}
+
+	// Overrides are held back until their recurring event is known
+	if gc.buffer.RecurrenceID != nil && !gc.buffer.IsRecurring {
+		gc.holdOverride(*gc.buffer)
+		return nil
+	}
+
+	gc.releaseHeld()
+
+	if gc.buffer.IsRecurring {
+		gc.recordMaster(gc.buffer)
+
+		if gc.Recurrence.Mode == RecurrenceModeMasters {
+			if instances, _ := gc.expand(gc.buffer, *gc.Start, *gc.End, 1); gc.SkipBounds || len(instances) > 0 {
+				gc.emitEvent(*gc.buffer)
+			}
+			return nil
+		}
+
+		instances := gc.ExpandRecurringEvent(gc.buffer)
+
+		// When streaming, instances are only kept until the end of the feed if an override was seen for them
+		if gc.streaming && !gc.pendingUids[gc.buffer.Uid] {
+			gc.held, gc.heldUid = instances, gc.buffer.Uid
+			return nil
+		}
+
+		gc.recurringInstances = append(gc.recurringInstances, instances...)
+		return nil
+	}
+
+	if !gc.SkipBounds && !gc.IsInRange(*gc.buffer) {
+		return nil
+	}
+
+	gc.emitEvent(*gc.buffer)
+
 	return nil
 }
 
+// holdOverride keeps an override until the end of the feed, along with the instances of its recurring event.
+func (gc *Gocal) holdOverride(o Event) {
+	gc.pendingOverrides = append(gc.pendingOverrides, o)
+
+	if !gc.streaming {
+		return
+	}
+
+	if gc.heldUid == o.Uid {
+		gc.recurringInstances = append(gc.recurringInstances, gc.held...)
+		gc.held, gc.heldUid = nil, ""
+	} else {
+		gc.releaseHeld()
+	}
+
+	if gc.pendingUids == nil {
+		gc.pendingUids = make(map[string]bool)
+	}
+	gc.pendingUids[o.Uid] = true
+}
+
+// releaseHeld returns the instances of the last recurring event, once an event other than one of its overrides follows it.
+func (gc *Gocal) releaseHeld() {
+	if gc.heldUid == "" {
+		return
+	}
+
+	for _, i := range gc.held {
+		if isInRange(i, *gc.Start, *gc.End) {
+			gc.emitEvent(i)
+		}
+	}
+
+	if gc.released == nil {
+		gc.released = make(map[string]bool)
+	}
+	gc.released[gc.heldUid] = true
+	gc.held, gc.heldUid = nil, ""
+}
+
+func (gc *Gocal) emitEvent(e Event) {
+	if e.RecurrenceID != nil {
+		if e.OccurrenceStart == nil {
+			e.OccurrenceStart = e.RecurrenceID
+		}
+		if e.Master == nil {
+			e.Master = gc.masters[e.Uid]
+		}
+	}
+
+	if gc.streaming {
+		gc.queue = append(gc.queue, e)
+		return
+	}
+
+	gc.Events = append(gc.Events, e)
+}
+
 func (gc *Gocal) parseLine() (*Line, error, bool) {
 	// Get initial current line and check if that was the last one
 	l := gc.scanner.Text()
-	done := !gc.scanner.Scan()
+	raw := gc.recordRaw("", l)
+	number := gc.physicalLine
+	done := gc.scan()
+
+	gc.logicalLine++
 
 	// If not, try and figure out if value is continued on next line
 	if !done {
 		for strings.HasPrefix(gc.scanner.Text(), " ") {
 			l = l + strings.TrimPrefix(gc.scanner.Text(), " ")
+			raw = gc.recordRaw(raw, gc.scanner.Text())
 
-			if done = !gc.scanner.Scan(); done {
+			if done = gc.scan(); done {
 				break
 			}
 		}
@@ -176,12 +614,59 @@ func (gc *Gocal) parseLine() (*Line, error, bool) {
 
 	tokens := splitLineTokens(l)
 	if len(tokens) < 2 {
+		gc.pending += raw
+		gc.line = &Line{number: number, logicalNumber: gc.logicalLine}
+		gc.warn(SeverityWarning, fmt.Errorf("ignoring unparsable line: %s", l))
 		return nil, fmt.Errorf("could not parse item: %s", l), done
 	}
 
 	attr, params := parser.ParseParameters(tokens[0])
 
-	return &Line{Key: attr, Params: params, Value: parser.UnescapeString(strings.TrimPrefix(tokens[1], " "))}, nil, done
+	value := strings.TrimPrefix(tokens[1], " ")
+	if textProperties[attr] {
+		value = parser.UnescapeText(value)
+	} else {
+		value = parser.UnescapeString(value)
+	}
+
+	line := &Line{Key: attr, Params: params, Value: value, number: number, logicalNumber: gc.logicalLine}
+	if gc.Lossless {
+		line = newRawLine(line, gc.pending+raw)
+		gc.pending = ""
+	}
+
+	return line, nil, done
+}
+
+// scan advances to the next physical line and reports if the feed is exhausted.
+func (gc *Gocal) scan() bool {
+	if !gc.scanner.Scan() {
+		gc.eol = ""
+		return true
+	}
+
+	gc.physicalLine++
+
+	return false
+}
+
+// scanLines splits physical lines like bufio.ScanLines, but remembers the line terminator that was used,
+// so lines can be written back as they were read.
+func (gc *Gocal) scanLines(data []byte, atEOF bool) (int, []byte, error) {
+	advance, token, err := bufio.ScanLines(data, atEOF)
+	if advance > 0 && token != nil {
+		gc.eol = string(data[len(token):advance])
+	}
+
+	return advance, token, err
+}
+
+func (gc *Gocal) recordRaw(raw, l string) string {
+	if !gc.Lossless {
+		return ""
+	}
+
+	return raw + l + gc.eol
 }
 
 // splitLineTokens assures that property parameters that are quoted due to containing special
@@ -269,31 +754,53 @@ func (gc *Gocal) parseEvent(l *Line) error {
 			return err
 		}
 	case "RRULE":
-		if len(gc.buffer.RecurrenceRule) != 0 {
-			return NewDuplicateAttribute(l.Key, l.Value)
+		// Several rules are allowed by RFC 2445, their occurrences are merged together
+		rule, err := ParseRRule(l.Value)
+		if err != nil {
+			return err
 		}
 
-		if gc.buffer.RecurrenceRule == nil || gc.Duplicate.Mode == DuplicateModeKeepLast {
-			var err error
-
-			gc.buffer.IsRecurring = true
-			if gc.buffer.RecurrenceRule, err = parser.ParseRecurrenceRule(l.Value); err != nil {
-				return err
-			}
+		gc.buffer.IsRecurring = true
+		gc.buffer.RecurrenceRule = append(gc.buffer.RecurrenceRule, *rule)
+	case "EXRULE":
+		rule, err := ParseRRule(l.Value)
+		if err != nil {
+			return err
 		}
+
+		gc.buffer.ExcludeRules = append(gc.buffer.ExcludeRules, *rule)
 	case "RECURRENCE-ID":
-		if err := resolve(gc, l, &gc.buffer.RecurrenceID, resolveString, nil); err != nil {
+		if err := resolve(gc, l, &gc.buffer.RecurrenceID, resolveDate, func(gc *Gocal, out *time.Time) {
+			gc.buffer.RawRecurrenceID = RawDate{Value: l.Value, Params: l.Params}
+		}); err != nil {
 			return err
 		}
 	case "EXDATE":
-		/*
-			Reference: https://icalendar.org/iCalendar-RFC-5545/3-8-5-1-exception-date-times.html
-			Several parameters are allowed.  We should pass parameters we have
-		*/
-		d, err := parser.ParseTime(l.Value, l.Params, parser.TimeStart, false, gc.AllDayEventsTZ)
-		if err == nil {
-			gc.buffer.ExcludeDates = append(gc.buffer.ExcludeDates, *d)
+		dates, days := gc.parseExcludeDates(l)
+		gc.buffer.ExcludeDates = append(gc.buffer.ExcludeDates, dates...)
+		gc.buffer.ExcludeDays = append(gc.buffer.ExcludeDays, days...)
+	case "RDATE":
+		// Reference: https://icalendar.org/iCalendar-RFC-5545/3-8-5-2-recurrence-date-times.html
+		for _, v := range strings.Split(l.Value, ",") {
+			if l.Params["VALUE"] == "PERIOD" {
+				start, end, err := gc.parsePeriod(v, l.Params)
+				if err != nil {
+					gc.warn(SeverityWarning, fmt.Errorf("ignoring unparsable recurrence date: %s", err))
+					continue
+				}
+				gc.buffer.RecurrenceDates = append(gc.buffer.RecurrenceDates, RecurrenceDate{Start: *start, End: end})
+				continue
+			}
+
+			d, err := gc.parseTime(v, l.Params, parser.TimeStart, false)
+			if err != nil {
+				gc.warn(SeverityWarning, fmt.Errorf("ignoring unparsable recurrence date: %s", err))
+				continue
+			}
+			gc.buffer.RecurrenceDates = append(gc.buffer.RecurrenceDates, RecurrenceDate{Start: *d})
 		}
+
+		gc.buffer.IsRecurring = true
 	case "SEQUENCE":
 		gc.buffer.Sequence, _ = strconv.Atoi(l.Value)
 	case "LOCATION":
@@ -309,36 +816,9 @@ func (gc *Gocal) parseEvent(l *Line) error {
 			return err
 		}
 	case "ATTENDEE":
-		attendee := Attendee{
-			Value: l.Value,
-		}
-		for key, val := range l.Params {
-			key := strings.ToUpper(key)
-			switch key {
-			case "CN":
-				attendee.Cn = val
-			case "DIR":
-				attendee.DirectoryDn = val
-			case "PARTSTAT":
-				attendee.Status = val
-			default:
-				if strings.HasPrefix(key, "X-") {
-					if attendee.CustomAttributes == nil {
-						attendee.CustomAttributes = make(map[string]string)
-					}
-					attendee.CustomAttributes[key] = val
-				}
-			}
-		}
-		gc.buffer.Attendees = append(gc.buffer.Attendees, attendee)
+		gc.buffer.Attendees = append(gc.buffer.Attendees, parseAttendee(l))
 	case "ATTACH":
-		gc.buffer.Attachments = append(gc.buffer.Attachments, Attachment{
-			Type:     l.Params["VALUE"],
-			Encoding: l.Params["ENCODING"],
-			Mime:     l.Params["FMTTYPE"],
-			Filename: l.Params["FILENAME"],
-			Value:    l.Value,
-		})
+		gc.buffer.Attachments = append(gc.buffer.Attachments, parseAttachment(l))
 	case "GEO":
 		if err := resolve(gc, l, &gc.buffer.Geo, resolveGeo, nil); err != nil {
 			return err
@@ -364,6 +844,60 @@ func (gc *Gocal) parseEvent(l *Line) error {
 	return nil
 }
 
+// handleAttributeError decides, from the strict and duplicate modes, if an attribute error aborts the feed.
+// If it does not, the component being parsed is marked as invalid.
+func (gc *Gocal) handleAttributeError(err error, valid *bool) error {
+	_, duplicate := err.(DuplicateAttributeError)
+	_, invalidRule := err.(RRuleError)
+
+	if (duplicate && gc.Duplicate.Mode == DuplicateModeFailStrict) || invalidRule {
+		switch gc.Strict.Mode {
+		case StrictModeFailEvent, StrictModeFailAttribute:
+			*valid = false
+			gc.warn(SeverityError, err)
+			return nil
+		}
+	}
+
+	return err
+}
+
+func parseAttendee(l *Line) Attendee {
+	attendee := Attendee{
+		Value: l.Value,
+	}
+	for key, val := range l.Params {
+		key := strings.ToUpper(key)
+		switch key {
+		case "CN":
+			attendee.Cn = val
+		case "DIR":
+			attendee.DirectoryDn = val
+		case "PARTSTAT":
+			attendee.Status = val
+		default:
+			if strings.HasPrefix(key, "X-") {
+				if attendee.CustomAttributes == nil {
+					attendee.CustomAttributes = make(map[string]string)
+				}
+				attendee.CustomAttributes[key] = val
+			}
+		}
+	}
+
+	return attendee
+}
+
+func parseAttachment(l *Line) Attachment {
+	return Attachment{
+		Type:     l.Params["VALUE"],
+		Encoding: l.Params["ENCODING"],
+		Mime:     l.Params["FMTTYPE"],
+		Filename: l.Params["FILENAME"],
+		Value:    l.Value,
+	}
+}
+
 func (gc *Gocal) checkEvent() error {
 	if gc.buffer.Uid == "" {
 		gc.buffer.Valid = false
@@ -384,6 +918,19 @@ func (gc *Gocal) checkEvent() error {
 	return nil
 }
 
+func (gc *Gocal) checkAlarm() error {
+	a := gc.buffer.Alarms[len(gc.buffer.Alarms)-1]
+
+	if a.Action == "" {
+		return fmt.Errorf("could not parse alarm without ACTION")
+	}
+	if a.Trigger == nil {
+		return fmt.Errorf("could not parse alarm without TRIGGER")
+	}
+
+	return nil
+}
+
 func SetTZMapper(cb func(s string) (*time.Location, error)) {
 	parser.TZMapper = cb
 }
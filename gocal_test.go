@@ -1,7 +1,10 @@
 package gocal
 
 import (
+	"errors"
 	"fmt"
+	"io"
+	"sort"
 	"strings"
 	"testing"
 	"time"
@@ -106,6 +109,18 @@ func Test_ParseLine(t *testing.T) {
 			expectValue:  "world",
 			expectParams: map[string]string{"KEY1": `"foo:value1"`, "KEY2": `"bar:value2"`},
 		},
+		{
+			from:         `DESCRIPTION:one\, two\nthree`,
+			expectKey:    "DESCRIPTION",
+			expectValue:  "one, two\nthree",
+			expectParams: map[string]string{},
+		},
+		{
+			from:         `X-PATH:C:\new\, two`,
+			expectKey:    "X-PATH",
+			expectValue:  `C:\new, two`,
+			expectParams: map[string]string{},
+		},
 	}
 
 	for idx, test := range tests {
@@ -584,6 +599,121 @@ func Test_DuplicateAttributes(t *testing.T) {
 	assert.Equal(t, "three@gocal", gc.Events[0].Uid)
 }
 
+const positionICS = `BEGIN:VCALENDAR
+BEGIN:VEVENT
+DTSTAMP:20151116T133227Z
+DTSTART;VALUE=DATE:20190101
+DESCRIPTION:A description that is folded
+  over two lines
+UID:one@gocal
+UID:two@gocal
+END:VEVENT
+END:VCALENDAR`
+
+func Test_ParseError(t *testing.T) {
+	start, end := time.Date(2018, 1, 1, 0, 0, 0, 0, time.Local), time.Date(2025, 2, 5, 23, 59, 59, 0, time.Local)
+
+	gc := NewParser(strings.NewReader(positionICS))
+	gc.Start, gc.End = &start, &end
+	err := gc.Parse()
+
+	var perr *ParseError
+	assert.True(t, errors.As(err, &perr))
+	assert.Equal(t, 8, perr.Line)
+	assert.Equal(t, 7, perr.LogicalLine)
+	assert.Equal(t, "VEVENT", perr.Component)
+	assert.Equal(t, "UID", perr.Property)
+	assert.Equal(t, "one@gocal", perr.Uid)
+	assert.Equal(t, "gocal error: line 8 in VEVENT (UID one@gocal), property UID: duplicate attribute UID: two@gocal", err.Error())
+
+	var dup DuplicateAttributeError
+	assert.True(t, errors.As(err, &dup))
+	assert.True(t, errors.Is(err, NewDuplicateAttribute("UID", "two@gocal")))
+
+	gc = NewParser(strings.NewReader(invalidICS))
+	gc.Start, gc.End = &start, &end
+	err = gc.Parse()
+
+	assert.True(t, errors.As(err, &perr))
+	assert.Equal(t, 7, perr.Line)
+	assert.Equal(t, "VEVENT", perr.Component)
+	assert.Equal(t, "", perr.Property)
+	assert.Equal(t, "one@gocal", perr.Uid)
+
+	gc = NewParser(strings.NewReader("BEGIN:VCALENDAR\nEND:VEVENT\nEND:VCALENDAR"))
+	gc.Start, gc.End = &start, &end
+	err = gc.Parse()
+
+	assert.True(t, errors.As(err, &perr))
+	assert.Equal(t, 2, perr.Line)
+}
+
+const diagnosticsICS = `BEGIN:VCALENDAR
+BEGIN:VEVENT
+DTSTART;TZID=Europe/Paris:20190101T090000
+DTEND;TZID=Europe/Paris:20190101T110000
+UID:one@gocal
+SUMMARY:Invalid event without DTSTAMP
+END:VEVENT
+BEGIN:VEVENT
+DTSTAMP:20151116T133227Z
+DTSTART;TZID=Nowhere/Special:20190201T090000
+DTEND;TZID=Nowhere/Special:20190201T110000
+UID:two@gocal
+UID:three@gocal
+RRULE:FREQ=DAILY;COUNT=2
+EXDATE:notadate
+this line is garbage
+END:VEVENT
+END:VCALENDAR`
+
+func Test_Warnings(t *testing.T) {
+	start, end := time.Date(2018, 1, 1, 0, 0, 0, 0, time.Local), time.Date(2020, 2, 5, 23, 59, 59, 0, time.Local)
+
+	gc := NewParser(strings.NewReader(diagnosticsICS))
+	gc.Start, gc.End = &start, &end
+	gc.Strict.Mode = StrictModeFailAttribute
+	err := gc.Parse()
+
+	assert.Nil(t, err)
+	assert.Len(t, gc.Warnings, 6)
+
+	assert.Equal(t, SeverityError, gc.Warnings[0].Severity)
+	assert.Equal(t, 7, gc.Warnings[0].Line)
+	assert.Equal(t, "one@gocal", gc.Warnings[0].Uid)
+	assert.Equal(t, "could not parse event without DTSTAMP", gc.Warnings[0].Err.Error())
+
+	assert.Equal(t, SeverityWarning, gc.Warnings[1].Severity)
+	assert.Equal(t, 10, gc.Warnings[1].Line)
+	assert.Equal(t, "DTSTART", gc.Warnings[1].Property)
+	assert.Equal(t, 11, gc.Warnings[2].Line)
+
+	assert.Equal(t, SeverityError, gc.Warnings[3].Severity)
+	assert.Equal(t, "UID", gc.Warnings[3].Property)
+	assert.Equal(t, "two@gocal", gc.Warnings[3].Uid)
+	assert.IsType(t, DuplicateAttributeError{}, gc.Warnings[3].Err)
+
+	assert.Equal(t, SeverityWarning, gc.Warnings[4].Severity)
+	assert.Equal(t, "EXDATE", gc.Warnings[4].Property)
+	assert.Equal(t, 15, gc.Warnings[4].Line)
+
+	assert.Equal(t, SeverityWarning, gc.Warnings[5].Severity)
+	assert.Equal(t, 16, gc.Warnings[5].Line)
+	assert.Equal(t, "VEVENT", gc.Warnings[5].Component)
+
+	gc = NewParser(strings.NewReader(diagnosticsICS))
+	gc.Start, gc.End = &start, &end
+	gc.Strict.Mode = StrictModeFailEvent
+	gc.Duplicate.Mode = DuplicateModeKeepFirst
+	err = gc.Parse()
+
+	assert.Nil(t, err)
+	assert.Len(t, gc.Events, 2)
+	assert.Len(t, gc.Warnings, 6)
+	assert.Equal(t, SeverityWarning, gc.Warnings[3].Severity)
+	assert.Equal(t, "UID", gc.Warnings[3].Property)
+}
+
 const recurrenceICSwithTZID = `BEGIN:VCALENDAR
 BEGIN:VEVENT
 DTSTART;TZID=Europe/Moscow:20240927T190000
@@ -630,3 +760,1151 @@ func Test_RecirrenceICSWithTZID(t *testing.T) {
 	assert.Equal(t, 1, len(gc.Events))
 	assert.Equal(t, "regular event", gc.Events[0].Summary)
 }
+
+const overrideICS = `BEGIN:VCALENDAR
+BEGIN:VEVENT
+UID:daily@gocal
+DTSTAMP:20151116T133227Z
+DTSTART;TZID=Europe/Paris:20190101T090000
+DTEND;TZID=Europe/Paris:20190101T100000
+SUMMARY:Daily
+RRULE:FREQ=DAILY;COUNT=7
+END:VEVENT
+BEGIN:VEVENT
+UID:daily@gocal
+DTSTAMP:20151116T133227Z
+RECURRENCE-ID;TZID=Europe/Paris:20190102T090000
+DTSTART:20190102T150000Z
+DTEND:20190102T160000Z
+SUMMARY:Moved to the afternoon
+END:VEVENT
+BEGIN:VEVENT
+UID:daily@gocal
+DTSTAMP:20151116T133227Z
+RECURRENCE-ID;RANGE=THISANDFUTURE;TZID=Europe/Paris:20190104T090000
+DTSTART;TZID=Europe/Paris:20190104T100000
+DTEND;TZID=Europe/Paris:20190104T103000
+SUMMARY:Shorter and later from now on
+END:VEVENT
+BEGIN:VEVENT
+UID:daily@gocal
+DTSTAMP:20151116T133227Z
+RECURRENCE-ID:20190106T080000Z
+DTSTART;TZID=Europe/Paris:20190106T120000
+DTEND;TZID=Europe/Paris:20190106T130000
+SUMMARY:Lunch on sunday
+END:VEVENT
+END:VCALENDAR`
+
+func Test_Overrides(t *testing.T) {
+	start, end := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2019, 1, 31, 0, 0, 0, 0, time.UTC)
+
+	gc := NewParser(strings.NewReader(overrideICS))
+	gc.Start, gc.End = &start, &end
+	err := gc.Parse()
+
+	assert.Nil(t, err)
+
+	events := make([]string, 0)
+	for _, e := range gc.Events {
+		events = append(events, fmt.Sprintf("%s %s-%s %s", e.Start.UTC().Format("2006-01-02"), e.Start.UTC().Format("15:04"), e.End.UTC().Format("15:04"), e.Summary))
+	}
+	sort.Strings(events)
+
+	assert.Equal(t, []string{
+		"2019-01-01 08:00-09:00 Daily",
+		"2019-01-02 15:00-16:00 Moved to the afternoon",
+		"2019-01-03 08:00-09:00 Daily",
+		"2019-01-04 09:00-09:30 Shorter and later from now on",
+		"2019-01-05 09:00-09:30 Shorter and later from now on",
+		"2019-01-06 11:00-12:00 Lunch on sunday",
+		"2019-01-07 09:00-09:30 Shorter and later from now on",
+	}, events)
+
+	for _, e := range gc.Events {
+		if e.Summary == "Moved to the afternoon" {
+			assert.Equal(t, time.Date(2019, 1, 2, 8, 0, 0, 0, time.UTC), e.RecurrenceID.UTC())
+			assert.Equal(t, "Europe/Paris", e.RawRecurrenceID.Params["TZID"])
+		}
+	}
+}
+
+const reconcileICS = `BEGIN:VCALENDAR
+BEGIN:VEVENT
+UID:moved-in@gocal
+DTSTAMP:20151116T133227Z
+RECURRENCE-ID:20190205T090000Z
+DTSTART:20190130T090000Z
+DTEND:20190130T100000Z
+SUMMARY:Moved into the range
+END:VEVENT
+BEGIN:VEVENT
+UID:moved-in@gocal
+DTSTAMP:20151116T133227Z
+DTSTART:20190101T090000Z
+DTEND:20190101T100000Z
+SUMMARY:Monthly
+RRULE:FREQ=MONTHLY;BYMONTHDAY=5
+END:VEVENT
+BEGIN:VEVENT
+UID:moved-out@gocal
+DTSTAMP:20151116T133227Z
+DTSTART:20190110T090000Z
+DTEND:20190110T100000Z
+SUMMARY:Weekly
+RRULE:FREQ=WEEKLY;COUNT=3
+END:VEVENT
+BEGIN:VEVENT
+UID:moved-out@gocal
+DTSTAMP:20151116T133227Z
+RECURRENCE-ID:20190117T090000Z
+DTSTART:20190301T090000Z
+DTEND:20190301T100000Z
+SUMMARY:Moved out of the range
+END:VEVENT
+BEGIN:VEVENT
+UID:cancelled@gocal
+DTSTAMP:20151116T133227Z
+DTSTART:20190102T120000Z
+DTEND:20190102T130000Z
+SUMMARY:Weekly lunch
+RRULE:FREQ=WEEKLY;COUNT=4
+END:VEVENT
+BEGIN:VEVENT
+UID:cancelled@gocal
+DTSTAMP:20151116T133227Z
+RECURRENCE-ID:20190109T120000Z
+DTSTART:20190109T120000Z
+DTEND:20190109T130000Z
+STATUS:CANCELLED
+SUMMARY:Weekly lunch
+END:VEVENT
+BEGIN:VEVENT
+UID:cancelled@gocal
+DTSTAMP:20151116T133227Z
+RECURRENCE-ID;RANGE=THISANDFUTURE:20190123T120000Z
+DTSTART:20190123T120000Z
+DTEND:20190123T130000Z
+STATUS:CANCELLED
+SUMMARY:Weekly lunch
+END:VEVENT
+BEGIN:VEVENT
+UID:orphaned@gocal
+DTSTAMP:20151116T133227Z
+DTSTART:20190103T150000Z
+DTEND:20190103T160000Z
+SUMMARY:Daily
+RRULE:FREQ=DAILY;COUNT=3
+EXDATE:20190104T150000Z
+END:VEVENT
+BEGIN:VEVENT
+UID:orphaned@gocal
+DTSTAMP:20151116T133227Z
+RECURRENCE-ID:20190104T150000Z
+DTSTART:20190104T170000Z
+DTEND:20190104T180000Z
+SUMMARY:Override of an excluded instance
+END:VEVENT
+BEGIN:VEVENT
+UID:orphaned@gocal
+DTSTAMP:20151116T133227Z
+RECURRENCE-ID:20190110T150000Z
+DTSTART:20190110T150000Z
+DTEND:20190110T160000Z
+SUMMARY:Override of a nonexistent instance
+END:VEVENT
+BEGIN:VEVENT
+UID:standalone@gocal
+DTSTAMP:20151116T133227Z
+RECURRENCE-ID:20190115T150000Z
+DTSTART:20190115T150000Z
+DTEND:20190115T160000Z
+SUMMARY:Override without recurring event
+END:VEVENT
+END:VCALENDAR`
+
+func Test_ReconcileOverrides(t *testing.T) {
+	start, end := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2019, 1, 31, 0, 0, 0, 0, time.UTC)
+
+	gc := NewParser(strings.NewReader(reconcileICS))
+	gc.Start, gc.End = &start, &end
+	err := gc.Parse()
+
+	assert.Nil(t, err)
+
+	events := make([]string, 0)
+	for _, e := range gc.Events {
+		events = append(events, e.Start.Format("2006-01-02 15:04")+" "+e.Summary)
+	}
+	sort.Strings(events)
+
+	assert.Equal(t, []string{
+		"2019-01-02 12:00 Weekly lunch",
+		"2019-01-03 15:00 Daily",
+		"2019-01-05 09:00 Monthly",
+		"2019-01-05 15:00 Daily",
+		"2019-01-10 09:00 Weekly",
+		"2019-01-15 15:00 Override without recurring event",
+		"2019-01-16 12:00 Weekly lunch",
+		"2019-01-24 09:00 Weekly",
+		"2019-01-30 09:00 Moved into the range",
+	}, events)
+}
+
+func Test_RecurrenceModeMasters(t *testing.T) {
+	start, end := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2019, 1, 31, 0, 0, 0, 0, time.UTC)
+
+	gc := NewParser(strings.NewReader(reconcileICS))
+	gc.Start, gc.End = &start, &end
+	gc.Recurrence.Mode = RecurrenceModeMasters
+	err := gc.Parse()
+
+	assert.Nil(t, err)
+
+	masters, overrides := make(map[string]*Event), make([]string, 0)
+	for i, e := range gc.Events {
+		assert.False(t, e.IsOccurrence() && !e.IsOverride())
+
+		if e.IsRecurring {
+			masters[e.Uid] = &gc.Events[i]
+		}
+		if e.IsOverride() {
+			overrides = append(overrides, e.Summary)
+		}
+	}
+
+	// Cancelled overrides, and the ones of excluded or nonexistent instances, are left out
+	assert.Len(t, masters, 4)
+	assert.ElementsMatch(t, []string{"Moved into the range", "Override without recurring event"}, overrides)
+	assert.Len(t, masters["moved-in@gocal"].RecurrenceRule, 1)
+
+	summaries := func(events []Event) []string {
+		s := make([]string, 0)
+		for _, e := range events {
+			s = append(s, e.Start.Format("2006-01-02 ")+e.Summary)
+		}
+		return s
+	}
+
+	assert.Equal(t, []string{"2019-01-05 Monthly", "2019-01-30 Moved into the range"}, summaries(gc.Occurrences(masters["moved-in@gocal"], start, end)))
+	assert.Equal(t, []string{"2019-01-02 Weekly lunch", "2019-01-16 Weekly lunch"}, summaries(gc.Occurrences(masters["cancelled@gocal"], start, end)))
+	assert.Equal(t, []string{"2019-03-05 Monthly"}, summaries(gc.Occurrences(masters["moved-in@gocal"], end, end.AddDate(0, 2, 0))))
+}
+
+const unboundedICS = `BEGIN:VCALENDAR
+BEGIN:VEVENT
+UID:secondly@gocal
+DTSTAMP:20151116T133227Z
+DTSTART:20190101T000000Z
+DTEND:20190101T000001Z
+SUMMARY:Every second
+RRULE:FREQ=SECONDLY
+END:VEVENT
+END:VCALENDAR`
+
+func Test_RecurrenceMaxInstances(t *testing.T) {
+	start, end := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2019, 12, 31, 0, 0, 0, 0, time.UTC)
+
+	gc := NewParser(strings.NewReader(unboundedICS))
+	gc.Start, gc.End = &start, &end
+	gc.Recurrence.MaxInstances = 100
+	err := gc.Parse()
+
+	assert.Nil(t, err)
+	assert.Len(t, gc.Events, 100)
+	assert.Len(t, gc.Warnings, 1)
+	assert.Equal(t, "secondly@gocal", gc.Warnings[0].Uid)
+
+	gc = NewParser(strings.NewReader(unboundedICS))
+	gc.Start, gc.End = &start, &end
+	gc.SkipBounds = true
+	gc.Recurrence.Mode = RecurrenceModeMasters
+	err = gc.Parse()
+
+	assert.Nil(t, err)
+	assert.Len(t, gc.Events, 1)
+	assert.Len(t, gc.Occurrences(&gc.Events[0], start, start.Add(time.Minute)), 58)
+}
+
+const hugeCountICS = `BEGIN:VCALENDAR
+BEGIN:VEVENT
+UID:minutely@gocal
+DTSTAMP:20151116T133227Z
+DTSTART:20150101T000000Z
+DTEND:20150101T000001Z
+RRULE:FREQ=MINUTELY;COUNT=100000000
+END:VEVENT
+BEGIN:VEVENT
+UID:secondly@gocal
+DTSTAMP:20151116T133227Z
+DTSTART:20150101T000000Z
+DTEND:20150101T000001Z
+RRULE:FREQ=SECONDLY;INTERVAL=7;BYHOUR=9,10;BYDAY=MO,WE;COUNT=100000000
+END:VEVENT
+END:VCALENDAR`
+
+func Test_RecurrenceHugeCount(t *testing.T) {
+	start, end := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2019, 12, 31, 0, 0, 0, 0, time.UTC)
+
+	gc := NewParser(strings.NewReader(hugeCountICS))
+	gc.Start, gc.End = &start, &end
+	gc.Recurrence.MaxInstances = 100
+
+	// Periods before the range are counted rather than walked one by one
+	done := make(chan error)
+	go func() { done <- gc.Parse() }()
+
+	select {
+	case err := <-done:
+		assert.Nil(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("expansion of rules with a huge COUNT timed out")
+	}
+
+	assert.Len(t, gc.Events, 200)
+	assert.Equal(t, time.Date(2019, 1, 1, 0, 1, 0, 0, time.UTC), *gc.Events[0].Start)
+	assert.Equal(t, time.Date(2019, 1, 2, 9, 0, 2, 0, time.UTC), *gc.Events[100].Start)
+}
+
+const occurrencesICS = `BEGIN:VCALENDAR
+BEGIN:VEVENT
+UID:weekly@gocal
+DTSTAMP:20151116T133227Z
+RECURRENCE-ID:20190108T090000Z
+DTSTART:20190108T140000Z
+DTEND:20190108T150000Z
+SEQUENCE:4
+SUMMARY:Moved
+END:VEVENT
+BEGIN:VEVENT
+UID:weekly@gocal
+DTSTAMP:20151116T133227Z
+DTSTART:20190101T090000Z
+DTEND:20190101T100000Z
+SEQUENCE:3
+SUMMARY:Weekly
+RRULE:FREQ=WEEKLY;COUNT=3
+END:VEVENT
+BEGIN:VEVENT
+UID:single@gocal
+DTSTAMP:20151116T133227Z
+DTSTART:20190102T090000Z
+DTEND:20190102T100000Z
+SUMMARY:Single
+END:VEVENT
+END:VCALENDAR`
+
+func Test_Occurrences(t *testing.T) {
+	start, end := time.Date(2018, 12, 1, 0, 0, 0, 0, time.UTC), time.Date(2019, 1, 31, 0, 0, 0, 0, time.UTC)
+
+	gc := NewParser(strings.NewReader(occurrencesICS))
+	gc.Start, gc.End = &start, &end
+	err := gc.Parse()
+
+	assert.Nil(t, err)
+	assert.Len(t, gc.Events, 4)
+
+	occurrences := make(map[string]Event)
+	for _, e := range gc.Events {
+		if e.IsOccurrence() {
+			occurrences[e.OccurrenceStart.Format("2006-01-02")] = e
+		} else {
+			assert.Equal(t, "single@gocal", e.Uid)
+			assert.False(t, e.IsOverride())
+			assert.Nil(t, e.Master)
+		}
+	}
+
+	assert.Len(t, occurrences, 3)
+
+	moved := occurrences["2019-01-08"]
+	assert.True(t, moved.IsOverride())
+	assert.Equal(t, "Moved", moved.Summary)
+	assert.Equal(t, 4, moved.Sequence)
+	assert.Equal(t, time.Date(2019, 1, 8, 14, 0, 0, 0, time.UTC), *moved.Start)
+	assert.Equal(t, time.Date(2019, 1, 8, 9, 0, 0, 0, time.UTC), *moved.OccurrenceStart)
+
+	for _, day := range []string{"2019-01-01", "2019-01-08", "2019-01-15"} {
+		e := occurrences[day]
+
+		assert.NotNil(t, e.Master, day)
+		assert.Equal(t, "Weekly", e.Master.Summary)
+		assert.Equal(t, time.Date(2019, 1, 1, 9, 0, 0, 0, time.UTC), *e.Master.Start)
+	}
+
+	assert.False(t, occurrences["2019-01-15"].IsOverride())
+	assert.Equal(t, 3, occurrences["2019-01-15"].Sequence)
+	assert.Equal(t, 3, occurrences["2019-01-01"].Sequence)
+}
+
+const timezoneICS = `BEGIN:VCALENDAR
+BEGIN:VTIMEZONE
+TZID:W. Europe Standard Time
+BEGIN:STANDARD
+DTSTART:16010101T030000
+TZOFFSETFROM:+0200
+TZOFFSETTO:+0100
+RRULE:FREQ=YEARLY;INTERVAL=1;BYDAY=-1SU;BYMONTH=10
+END:STANDARD
+BEGIN:DAYLIGHT
+DTSTART:16010101T020000
+TZOFFSETFROM:+0100
+TZOFFSETTO:+0200
+RRULE:FREQ=YEARLY;INTERVAL=1;BYDAY=-1SU;BYMONTH=3
+END:DAYLIGHT
+END:VTIMEZONE
+BEGIN:VTIMEZONE
+TZID:Custom Fixed
+BEGIN:STANDARD
+DTSTART:19700101T000000
+TZOFFSETFROM:+0530
+TZOFFSETTO:+0530
+TZNAME:IST
+END:STANDARD
+END:VTIMEZONE
+BEGIN:VEVENT
+UID:winter@gocal
+DTSTAMP:20151116T133227Z
+DTSTART;TZID=W. Europe Standard Time:20240115T090000
+DTEND;TZID=W. Europe Standard Time:20240115T100000
+SUMMARY:Winter meeting
+END:VEVENT
+BEGIN:VEVENT
+UID:summer@gocal
+DTSTAMP:20151116T133227Z
+DTSTART;TZID=W. Europe Standard Time:20240715T090000
+DTEND;TZID=W. Europe Standard Time:20240715T100000
+SUMMARY:Summer meeting
+END:VEVENT
+BEGIN:VEVENT
+UID:fixed@gocal
+DTSTAMP:20151116T133227Z
+DTSTART;TZID="Custom Fixed":20240715T090000
+DTEND;TZID="Custom Fixed":20240715T100000
+SUMMARY:Fixed offset meeting
+END:VEVENT
+END:VCALENDAR`
+
+func Test_Timezones(t *testing.T) {
+	start, end := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 12, 31, 0, 0, 0, 0, time.UTC)
+
+	gc := NewParser(strings.NewReader(timezoneICS))
+	gc.Start, gc.End = &start, &end
+	err := gc.Parse()
+
+	assert.Nil(t, err)
+	assert.Len(t, gc.Timezones, 2)
+	assert.Len(t, gc.Events, 3)
+
+	assert.Equal(t, time.Date(2024, 1, 15, 8, 0, 0, 0, time.UTC), gc.Events[0].Start.UTC())
+	assert.Equal(t, time.Date(2024, 7, 15, 7, 0, 0, 0, time.UTC), gc.Events[1].Start.UTC())
+	assert.Equal(t, time.Date(2024, 7, 15, 3, 30, 0, 0, time.UTC), gc.Events[2].Start.UTC())
+	assert.Equal(t, "W. Europe Standard Time", gc.Events[0].Start.Location().String())
+
+	// Transitions happen on the last sunday of March and October
+	name, offset := time.Date(2024, 3, 31, 0, 59, 0, 0, time.UTC).In(gc.Events[0].Start.Location()).Zone()
+	assert.Equal(t, "+0100", name)
+	assert.Equal(t, 3600, offset)
+
+	_, offset = time.Date(2024, 3, 31, 1, 0, 0, 0, time.UTC).In(gc.Events[0].Start.Location()).Zone()
+	assert.Equal(t, 7200, offset)
+
+	_, offset = time.Date(2024, 10, 27, 1, 0, 0, 0, time.UTC).In(gc.Events[0].Start.Location()).Zone()
+	assert.Equal(t, 3600, offset)
+}
+
+const timezoneUntilICS = `BEGIN:VCALENDAR
+BEGIN:VTIMEZONE
+TZID:Eastern
+BEGIN:DAYLIGHT
+DTSTART:19870405T020000
+TZOFFSETFROM:-0500
+TZOFFSETTO:-0400
+RRULE:FREQ=YEARLY;BYMONTH=4;BYDAY=1SU;UNTIL=20060402T070000Z
+END:DAYLIGHT
+BEGIN:DAYLIGHT
+DTSTART:20070311T020000
+TZOFFSETFROM:-0500
+TZOFFSETTO:-0400
+RRULE:FREQ=YEARLY;BYMONTH=3;BYDAY=2SU
+END:DAYLIGHT
+BEGIN:STANDARD
+DTSTART:19671029T020000
+TZOFFSETFROM:-0400
+TZOFFSETTO:-0500
+RRULE:FREQ=YEARLY;BYMONTH=10;BYDAY=-1SU;UNTIL=20061029T060000Z
+END:STANDARD
+BEGIN:STANDARD
+DTSTART:20071104T020000
+TZOFFSETFROM:-0400
+TZOFFSETTO:-0500
+RRULE:FREQ=YEARLY;BYMONTH=11;BYDAY=1SU
+END:STANDARD
+END:VTIMEZONE
+END:VCALENDAR`
+
+func Test_TimezoneRuleUntil(t *testing.T) {
+	gc := NewParser(strings.NewReader(timezoneUntilICS))
+	err := gc.Parse()
+
+	assert.Nil(t, err)
+	assert.Equal(t, "YEARLY", gc.Timezones["Eastern"].Observances[0].RecurrenceRule.Freq)
+
+	loc := gc.Timezones["Eastern"].Location
+	offsets := map[time.Time]int{
+		time.Date(2006, 4, 2, 6, 59, 0, 0, time.UTC):   -5 * 3600,
+		time.Date(2006, 4, 2, 7, 0, 0, 0, time.UTC):    -4 * 3600,
+		time.Date(2006, 10, 29, 6, 0, 0, 0, time.UTC):  -5 * 3600,
+		time.Date(2007, 3, 20, 12, 0, 0, 0, time.UTC):  -4 * 3600,
+		time.Date(2007, 10, 30, 12, 0, 0, 0, time.UTC): -4 * 3600,
+		time.Date(2007, 11, 4, 6, 0, 0, 0, time.UTC):   -5 * 3600,
+	}
+
+	// UNTIL is given in UTC, and ends the rules used up to 2006
+	for at, expected := range offsets {
+		_, offset := at.In(loc).Zone()
+		assert.Equal(t, expected, offset, at.String())
+	}
+}
+
+const lateTimezoneICS = `BEGIN:VCALENDAR
+BEGIN:VEVENT
+UID:summer@gocal
+DTSTAMP:20151116T133227Z
+DTSTART;TZID=W. Europe Standard Time:20240715T090000
+DTEND;TZID=W. Europe Standard Time:20240715T100000
+SUMMARY:Summer meeting
+END:VEVENT
+BEGIN:VEVENT
+UID:nowhere@gocal
+DTSTAMP:20151116T133227Z
+DTSTART;TZID=Nowhere/Special:20240716T090000
+DTEND;TZID=Nowhere/Special:20240716T100000
+SUMMARY:Meeting in an unknown timezone
+END:VEVENT
+BEGIN:VTIMEZONE
+TZID:W. Europe Standard Time
+BEGIN:STANDARD
+DTSTART:16010101T030000
+TZOFFSETFROM:+0200
+TZOFFSETTO:+0100
+RRULE:FREQ=YEARLY;INTERVAL=1;BYDAY=-1SU;BYMONTH=10
+END:STANDARD
+BEGIN:DAYLIGHT
+DTSTART:16010101T020000
+TZOFFSETFROM:+0100
+TZOFFSETTO:+0200
+RRULE:FREQ=YEARLY;INTERVAL=1;BYDAY=-1SU;BYMONTH=3
+END:DAYLIGHT
+END:VTIMEZONE
+END:VCALENDAR`
+
+func Test_TimezoneDefinedLater(t *testing.T) {
+	start, end := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 12, 31, 0, 0, 0, 0, time.UTC)
+
+	gc := NewParser(strings.NewReader(lateTimezoneICS))
+	gc.Start, gc.End = &start, &end
+	err := gc.Parse()
+
+	assert.Nil(t, err)
+	assert.Len(t, gc.Events, 2)
+
+	assert.Equal(t, "summer@gocal", gc.Events[0].Uid)
+	assert.Equal(t, time.Date(2024, 7, 15, 7, 0, 0, 0, time.UTC), gc.Events[0].Start.UTC())
+	assert.Equal(t, "W. Europe Standard Time", gc.Events[0].Start.Location().String())
+
+	// Timezones that are never defined fall back to UTC once the feed is over
+	assert.Equal(t, "nowhere@gocal", gc.Events[1].Uid)
+	assert.Equal(t, time.Date(2024, 7, 16, 9, 0, 0, 0, time.UTC), gc.Events[1].Start.UTC())
+
+	assert.Len(t, gc.Warnings, 2)
+	assert.Equal(t, 12, gc.Warnings[0].Line)
+	assert.Equal(t, "nowhere@gocal", gc.Warnings[0].Uid)
+	assert.Equal(t, "unknown timezone Nowhere/Special, falling back to UTC", gc.Warnings[0].Err.Error())
+	assert.Equal(t, 13, gc.Warnings[1].Line)
+}
+
+const todoICS = `BEGIN:VCALENDAR
+BEGIN:VTODO
+UID:todo1@gocal
+DTSTAMP:20151116T133227Z
+DTSTART:20240110T090000Z
+DUE:20240112T170000Z
+SUMMARY:Write report
+PRIORITY:1
+PERCENT-COMPLETE:40
+STATUS:IN-PROCESS
+END:VTODO
+BEGIN:VTODO
+UID:todo2@gocal
+DTSTAMP:20151116T133227Z
+DUE:20240301T170000Z
+COMPLETED:20240228T120000Z
+SUMMARY:Out of range
+STATUS:COMPLETED
+END:VTODO
+BEGIN:VTODO
+UID:todo3@gocal
+DTSTAMP:20151116T133227Z
+SUMMARY:Undated
+END:VTODO
+BEGIN:VTODO
+UID:todo4@gocal
+DTSTAMP:20151116T133227Z
+DUE:20240105T170000Z
+RRULE:FREQ=WEEKLY;COUNT=3
+SUMMARY:Weekly
+END:VTODO
+BEGIN:VEVENT
+UID:event@gocal
+DTSTAMP:20151116T133227Z
+DTSTART:20240110T090000Z
+DTEND:20240110T100000Z
+SUMMARY:Event
+END:VEVENT
+END:VCALENDAR`
+
+func Test_Todos(t *testing.T) {
+	start, end := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC)
+
+	gc := NewParser(strings.NewReader(todoICS))
+	gc.Start, gc.End = &start, &end
+	err := gc.Parse()
+
+	assert.Nil(t, err)
+	assert.Len(t, gc.Events, 1)
+	assert.Len(t, gc.Todos, 5)
+
+	assert.Equal(t, "Write report", gc.Todos[0].Summary)
+	assert.Equal(t, 1, gc.Todos[0].Priority)
+	assert.Equal(t, 40, gc.Todos[0].PercentComplete)
+	assert.Equal(t, "IN-PROCESS", gc.Todos[0].Status)
+	assert.Equal(t, time.Date(2024, 1, 12, 17, 0, 0, 0, time.UTC), *gc.Todos[0].Due)
+
+	assert.Equal(t, "Undated", gc.Todos[1].Summary)
+
+	assert.Equal(t, "Weekly", gc.Todos[2].Summary)
+	assert.Nil(t, gc.Todos[2].Start)
+	assert.Equal(t, time.Date(2024, 1, 5, 17, 0, 0, 0, time.UTC), *gc.Todos[2].Due)
+	assert.Equal(t, time.Date(2024, 1, 19, 17, 0, 0, 0, time.UTC), *gc.Todos[4].Due)
+}
+
+const invalidTodoICS = `BEGIN:VCALENDAR
+BEGIN:VTODO
+UID:todo1@gocal
+UID:todo2@gocal
+DTSTAMP:20151116T133227Z
+SUMMARY:Duplicate UID
+END:VTODO
+END:VCALENDAR`
+
+func Test_InvalidTodo(t *testing.T) {
+	gc := NewParser(strings.NewReader(invalidTodoICS))
+	err := gc.Parse()
+
+	assert.NotNil(t, err)
+
+	gc = NewParser(strings.NewReader(invalidTodoICS))
+	gc.Strict.Mode = StrictModeFailAttribute
+	err = gc.Parse()
+
+	assert.Nil(t, err)
+	assert.Len(t, gc.Todos, 1)
+	assert.False(t, gc.Todos[0].Valid)
+
+	gc = NewParser(strings.NewReader(invalidTodoICS))
+	gc.Strict.Mode = StrictModeFailEvent
+	err = gc.Parse()
+
+	assert.Nil(t, err)
+	assert.Empty(t, gc.Todos)
+}
+
+const duplicateRRuleTodoICS = `BEGIN:VCALENDAR
+BEGIN:VTODO
+UID:todo@gocal
+DTSTAMP:20151116T133227Z
+DUE:20240105T170000Z
+RRULE:FREQ=WEEKLY;COUNT=2
+RRULE:FREQ=DAILY;COUNT=2
+SUMMARY:Two rules
+END:VTODO
+END:VCALENDAR`
+
+func Test_TodoDuplicateRRule(t *testing.T) {
+	start, end := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC)
+
+	gc := NewParser(strings.NewReader(duplicateRRuleTodoICS))
+	gc.Start, gc.End = &start, &end
+	err := gc.Parse()
+
+	assert.NotNil(t, err)
+	assert.IsType(t, DuplicateAttributeError{}, errors.Unwrap(err))
+
+	gc = NewParser(strings.NewReader(duplicateRRuleTodoICS))
+	gc.Start, gc.End = &start, &end
+	gc.Duplicate.Mode = DuplicateModeKeepFirst
+	err = gc.Parse()
+
+	assert.Nil(t, err)
+	assert.Len(t, gc.Todos, 2)
+	assert.Equal(t, "WEEKLY", gc.Todos[0].RecurrenceRule.Freq)
+	assert.Equal(t, time.Date(2024, 1, 12, 17, 0, 0, 0, time.UTC), *gc.Todos[1].Due)
+	assert.Len(t, gc.Warnings, 1)
+	assert.Equal(t, "RRULE", gc.Warnings[0].Property)
+
+	gc = NewParser(strings.NewReader(duplicateRRuleTodoICS))
+	gc.Start, gc.End = &start, &end
+	gc.Duplicate.Mode = DuplicateModeKeepLast
+	err = gc.Parse()
+
+	assert.Nil(t, err)
+	assert.Len(t, gc.Todos, 2)
+	assert.Equal(t, "DAILY", gc.Todos[0].RecurrenceRule.Freq)
+	assert.Equal(t, time.Date(2024, 1, 6, 17, 0, 0, 0, time.UTC), *gc.Todos[1].Due)
+	assert.Len(t, gc.Warnings, 1)
+}
+
+const journalICS = `BEGIN:VCALENDAR
+BEGIN:VJOURNAL
+UID:journal1@gocal
+DTSTAMP:20151116T133227Z
+DTSTART;VALUE=DATE:20240110
+SUMMARY:Staff meeting minutes
+DESCRIPTION:1. Staff meeting: Participants include Joe\, Lisa and Bob.
+DESCRIPTION:2. Telephone conference: Participants include Sue.
+CATEGORIES:MEETING,MINUTES
+ATTACH;FMTTYPE=text/plain:https://example.com/minutes.txt
+STATUS:FINAL
+END:VJOURNAL
+BEGIN:VJOURNAL
+UID:journal2@gocal
+DTSTAMP:20151116T133227Z
+DTSTART:20240102T090000Z
+RRULE:FREQ=WEEKLY;COUNT=10
+EXDATE:20240116T090000Z
+SUMMARY:Weekly notes
+END:VJOURNAL
+BEGIN:VJOURNAL
+UID:journal3@gocal
+DTSTAMP:20151116T133227Z
+DTSTART:20230102T090000Z
+SUMMARY:Out of range
+END:VJOURNAL
+END:VCALENDAR`
+
+func Test_Journals(t *testing.T) {
+	start, end := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC)
+
+	gc := NewParser(strings.NewReader(journalICS))
+	gc.Start, gc.End = &start, &end
+	err := gc.Parse()
+
+	assert.Nil(t, err)
+	assert.Len(t, gc.Journals, 5)
+
+	assert.Equal(t, "Staff meeting minutes", gc.Journals[0].Summary)
+	assert.Equal(t, []string{"1. Staff meeting: Participants include Joe, Lisa and Bob.", "2. Telephone conference: Participants include Sue."}, gc.Journals[0].Descriptions)
+	assert.Equal(t, []string{"MEETING", "MINUTES"}, gc.Journals[0].Categories)
+	assert.Len(t, gc.Journals[0].Attachments, 1)
+	assert.Equal(t, "text/plain", gc.Journals[0].Attachments[0].Mime)
+	assert.Equal(t, "FINAL", gc.Journals[0].Status)
+
+	assert.Equal(t, time.Date(2024, 1, 2, 9, 0, 0, 0, time.UTC), *gc.Journals[1].Start)
+	assert.Equal(t, time.Date(2024, 1, 9, 9, 0, 0, 0, time.UTC), *gc.Journals[2].Start)
+	assert.Equal(t, time.Date(2024, 1, 23, 9, 0, 0, 0, time.UTC), *gc.Journals[3].Start)
+	assert.Equal(t, time.Date(2024, 1, 30, 9, 0, 0, 0, time.UTC), *gc.Journals[4].Start)
+}
+
+const duplicateRRuleJournalICS = `BEGIN:VCALENDAR
+BEGIN:VJOURNAL
+UID:journal@gocal
+DTSTAMP:20151116T133227Z
+DTSTART:20240102T090000Z
+RRULE:FREQ=WEEKLY;COUNT=2
+RRULE:FREQ=DAILY;COUNT=2
+SUMMARY:Two rules
+END:VJOURNAL
+END:VCALENDAR`
+
+func Test_JournalDuplicateRRule(t *testing.T) {
+	start, end := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC)
+
+	gc := NewParser(strings.NewReader(duplicateRRuleJournalICS))
+	gc.Start, gc.End = &start, &end
+	err := gc.Parse()
+
+	assert.NotNil(t, err)
+	assert.IsType(t, DuplicateAttributeError{}, errors.Unwrap(err))
+
+	gc = NewParser(strings.NewReader(duplicateRRuleJournalICS))
+	gc.Start, gc.End = &start, &end
+	gc.Duplicate.Mode = DuplicateModeKeepFirst
+	err = gc.Parse()
+
+	assert.Nil(t, err)
+	assert.Len(t, gc.Journals, 2)
+	assert.Equal(t, "WEEKLY", gc.Journals[0].RecurrenceRule.Freq)
+	assert.Equal(t, time.Date(2024, 1, 9, 9, 0, 0, 0, time.UTC), *gc.Journals[1].Start)
+	assert.Len(t, gc.Warnings, 1)
+	assert.Equal(t, "RRULE", gc.Warnings[0].Property)
+
+	gc = NewParser(strings.NewReader(duplicateRRuleJournalICS))
+	gc.Start, gc.End = &start, &end
+	gc.Duplicate.Mode = DuplicateModeKeepLast
+	err = gc.Parse()
+
+	assert.Nil(t, err)
+	assert.Len(t, gc.Journals, 2)
+	assert.Equal(t, "DAILY", gc.Journals[0].RecurrenceRule.Freq)
+	assert.Equal(t, time.Date(2024, 1, 3, 9, 0, 0, 0, time.UTC), *gc.Journals[1].Start)
+	assert.Len(t, gc.Warnings, 1)
+}
+
+const alarmICS = `BEGIN:VCALENDAR
+BEGIN:VEVENT
+UID:alarm@gocal
+DTSTAMP:20151116T133227Z
+DTSTART:20240101T090000Z
+DTEND:20240101T100000Z
+RRULE:FREQ=DAILY;COUNT=3
+SUMMARY:Daily standup
+BEGIN:VALARM
+ACTION:DISPLAY
+DESCRIPTION:Standup in 15 minutes
+TRIGGER:-PT15M
+REPEAT:2
+DURATION:PT5M
+END:VALARM
+BEGIN:VALARM
+ACTION:EMAIL
+SUMMARY:Standup is over
+DESCRIPTION:Write the notes
+TRIGGER;RELATED=END:PT0S
+ATTENDEE:mailto:john.connor@example.net
+END:VALARM
+BEGIN:VALARM
+ACTION:AUDIO
+TRIGGER;VALUE=DATE-TIME:20231231T120000Z
+END:VALARM
+END:VEVENT
+END:VCALENDAR`
+
+func Test_Alarms(t *testing.T) {
+	start, end := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC)
+
+	gc := NewParser(strings.NewReader(alarmICS))
+	gc.Start, gc.End = &start, &end
+	err := gc.Parse()
+
+	assert.Nil(t, err)
+	assert.Len(t, gc.Events, 3)
+	assert.Len(t, gc.Events[0].Alarms, 3)
+
+	a := gc.Events[0].Alarms
+	assert.Equal(t, "DISPLAY", a[0].Action)
+	assert.Equal(t, TriggerRelatedStart, a[0].Trigger.Related)
+	assert.Equal(t, -15*time.Minute, *a[0].Trigger.Duration)
+	assert.Equal(t, 2, a[0].Repeat)
+	assert.Equal(t, TriggerRelatedEnd, a[1].Trigger.Related)
+	assert.Equal(t, "mailto:john.connor@example.net", a[1].Attendees[0].Value)
+	assert.Equal(t, time.Date(2023, 12, 31, 12, 0, 0, 0, time.UTC), *a[2].Trigger.Time)
+
+	assert.Equal(t, []time.Time{
+		time.Date(2024, 1, 2, 8, 45, 0, 0, time.UTC),
+		time.Date(2024, 1, 2, 8, 50, 0, 0, time.UTC),
+		time.Date(2024, 1, 2, 8, 55, 0, 0, time.UTC),
+	}, a[0].FireTimes(gc.Events[1]))
+
+	// 3 occurrences with 3 + 1 fire times, and a single absolute alarm
+	occurrences := gc.AlarmOccurrences()
+	assert.Len(t, occurrences, 13)
+	assert.Equal(t, time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC), occurrences[3].Time)
+	assert.Equal(t, "EMAIL", occurrences[3].Alarm.Action)
+}
+
+const alarmOverrideICS = `BEGIN:VEVENT
+UID:alarm@gocal
+DTSTAMP:20151116T133227Z
+RECURRENCE-ID:20240102T090000Z
+DTSTART:20240102T110000Z
+DTEND:20240102T120000Z
+SUMMARY:Late standup
+BEGIN:VALARM
+ACTION:DISPLAY
+TRIGGER:-PT10M
+END:VALARM
+END:VEVENT
+`
+
+func Test_AlarmsMastersMode(t *testing.T) {
+	start, end := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC)
+	ics := strings.Replace(alarmICS, "END:VCALENDAR", alarmOverrideICS+"END:VCALENDAR", 1)
+
+	fireTimes := func(mode int) []time.Time {
+		gc := NewParser(strings.NewReader(ics))
+		gc.Start, gc.End = &start, &end
+		gc.Recurrence.Mode = mode
+		err := gc.Parse()
+
+		assert.Nil(t, err)
+
+		times := make([]time.Time, 0)
+		for _, o := range gc.AlarmOccurrences() {
+			times = append(times, o.Time)
+		}
+		sort.Slice(times, func(i, j int) bool { return times[i].Before(times[j]) })
+
+		return times
+	}
+
+	// Recurring events are expanded, and their overrides are not counted twice
+	expanded, masters := fireTimes(RecurrenceModeExpand), fireTimes(RecurrenceModeMasters)
+
+	assert.Len(t, masters, 10)
+	assert.Equal(t, expanded, masters)
+	assert.Contains(t, masters, time.Date(2024, 1, 2, 10, 50, 0, 0, time.UTC))
+	assert.Contains(t, masters, time.Date(2024, 1, 3, 8, 45, 0, 0, time.UTC))
+}
+
+const invalidAlarmICS = `BEGIN:VCALENDAR
+BEGIN:VEVENT
+UID:alarm@gocal
+DTSTAMP:20151116T133227Z
+DTSTART:20240101T090000Z
+DTEND:20240101T100000Z
+BEGIN:VALARM
+ACTION:DISPLAY
+END:VALARM
+END:VEVENT
+END:VCALENDAR`
+
+func Test_InvalidAlarm(t *testing.T) {
+	start, end := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC)
+
+	gc := NewParser(strings.NewReader(invalidAlarmICS))
+	gc.Start, gc.End = &start, &end
+	err := gc.Parse()
+
+	assert.NotNil(t, err)
+
+	gc = NewParser(strings.NewReader(invalidAlarmICS))
+	gc.Start, gc.End = &start, &end
+	gc.Strict.Mode = StrictModeFailAttribute
+	err = gc.Parse()
+
+	assert.Nil(t, err)
+	assert.Len(t, gc.Events, 1)
+	assert.Empty(t, gc.Events[0].Alarms)
+	assert.False(t, gc.Events[0].Valid)
+}
+
+const freeBusyICS = `BEGIN:VCALENDAR
+METHOD:REPLY
+BEGIN:VFREEBUSY
+UID:freebusy@gocal
+DTSTAMP:20151116T133227Z
+ORGANIZER;CN=John Connor:mailto:john.connor@example.net
+ATTENDEE:mailto:antoine.popineau@example.net
+DTSTART:20240101T080000Z
+DTEND:20240102T180000Z
+FREEBUSY:20240101T090000Z/20240101T100000Z
+FREEBUSY;FBTYPE=BUSY-TENTATIVE:20240101T140000Z/PT1H30M,20240101T160000Z/PT30M
+FREEBUSY;FBTYPE=FREE:20240102T080000Z/20240102T180000Z
+END:VFREEBUSY
+END:VCALENDAR`
+
+func Test_FreeBusy(t *testing.T) {
+	gc := NewParser(strings.NewReader(freeBusyICS))
+	err := gc.Parse()
+
+	assert.Nil(t, err)
+	assert.Len(t, gc.FreeBusy, 1)
+
+	fb := gc.FreeBusy[0]
+	assert.Equal(t, "John Connor", fb.Organizer.Cn)
+	assert.Equal(t, "mailto:antoine.popineau@example.net", fb.Attendees[0].Value)
+	assert.Equal(t, time.Date(2024, 1, 1, 8, 0, 0, 0, time.UTC), *fb.Start)
+	assert.Len(t, fb.Periods, 4)
+	assert.Len(t, fb.Busy(), 3)
+
+	assert.Equal(t, FreeBusyPeriod{Type: FreeBusyTypeBusy, Start: time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC), End: time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)}, fb.Periods[0])
+	assert.Equal(t, FreeBusyTypeBusyTentative, fb.Periods[1].Type)
+	assert.Equal(t, 90*time.Minute, fb.Periods[1].Duration())
+	assert.Equal(t, time.Date(2024, 1, 1, 16, 30, 0, 0, time.UTC), fb.Periods[2].End)
+	assert.Equal(t, FreeBusyTypeFree, fb.Periods[3].Type)
+}
+
+func Test_Next(t *testing.T) {
+	start, end := time.Date(2018, 1, 1, 0, 0, 0, 0, time.Local), time.Date(2018, 2, 5, 23, 59, 59, 0, time.Local)
+
+	gc := NewParser(strings.NewReader(recuringICS))
+	gc.Start, gc.End = &start, &end
+	gc.Parse()
+
+	stream := NewParser(strings.NewReader(recuringICS))
+	stream.Start, stream.End = &start, &end
+
+	events := make([]Event, 0)
+	for {
+		e, err := stream.Next()
+		if err == io.EOF {
+			break
+		}
+
+		assert.Nil(t, err)
+		events = append(events, *e)
+	}
+
+	// Instances of recurring events without overrides are returned before the end of the feed
+	assert.ElementsMatch(t, gc.Events, events)
+	assert.Empty(t, stream.Events)
+
+	_, err := stream.Next()
+	assert.Equal(t, io.EOF, err)
+}
+
+func Test_NextOverrideAfterMaster(t *testing.T) {
+	start, end := time.Date(2024, 10, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 10, 31, 23, 59, 59, 0, time.UTC)
+
+	gc := NewParser(strings.NewReader(recurrenceICSwithTZID))
+	gc.Start, gc.End = &start, &end
+
+	summaries := make([]string, 0)
+	for {
+		e, err := gc.Next()
+		if err != nil {
+			break
+		}
+
+		summaries = append(summaries, e.Summary)
+	}
+
+	// Overrides are held back with the instances until the end of the feed, and take the place of the instance they replace
+	assert.Equal(t, []string{"regular event", "regular event", "regular event", "not ordinary event"}, summaries)
+}
+
+const streamICS = `BEGIN:VCALENDAR
+BEGIN:VEVENT
+UID:daily@gocal
+DTSTAMP:20151116T133227Z
+DTSTART:20240101T090000Z
+DTEND:20240101T100000Z
+SUMMARY:Daily
+RRULE:FREQ=DAILY;COUNT=3
+END:VEVENT
+BEGIN:VEVENT
+UID:single@gocal
+DTSTAMP:20151116T133227Z
+DTSTART:20240105T090000Z
+DTEND:20240105T100000Z
+SUMMARY:Single
+END:VEVENT
+BEGIN:VEVENT
+UID:late@gocal
+DTSTAMP:20151116T133227Z
+DTSTART:20240110T090000Z
+DTEND:20240110T100000Z
+SUMMARY:Late
+RRULE:FREQ=DAILY;COUNT=2
+END:VEVENT
+BEGIN:VEVENT
+UID:single2@gocal
+DTSTAMP:20151116T133227Z
+DTSTART:20240115T090000Z
+DTEND:20240115T100000Z
+SUMMARY:Single
+END:VEVENT
+BEGIN:VEVENT
+UID:late@gocal
+DTSTAMP:20151116T133227Z
+DTSTART:20240111T120000Z
+DTEND:20240111T130000Z
+RECURRENCE-ID:20240111T090000Z
+SUMMARY:Late override
+END:VEVENT
+END:VCALENDAR`
+
+func Test_NextBeforeEnd(t *testing.T) {
+	start, end := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC)
+
+	gc := NewParser(strings.NewReader(streamICS))
+	gc.Start, gc.End = &start, &end
+
+	// The instances of a recurring event are returned once an event that does not override it follows
+	for i := 0; i < 3; i++ {
+		e, err := gc.Next()
+
+		assert.Nil(t, err)
+		assert.Equal(t, "Daily", e.Summary)
+		assert.False(t, gc.ended)
+	}
+
+	summaries := make([]string, 0)
+	for {
+		e, err := gc.Next()
+		if err != nil {
+			break
+		}
+
+		summaries = append(summaries, e.Summary)
+	}
+
+	// Overrides found after the instances they replace were returned are returned as they are
+	assert.Equal(t, []string{"Single", "Late", "Late", "Single", "Late override"}, summaries)
+}
+
+func Test_NextError(t *testing.T) {
+	start, end := time.Date(2018, 1, 1, 0, 0, 0, 0, time.Local), time.Date(2020, 2, 5, 23, 59, 59, 0, time.Local)
+
+	gc := NewParser(strings.NewReader(invalidICS))
+	gc.Start, gc.End = &start, &end
+
+	e, err := gc.Next()
+
+	assert.Nil(t, e)
+	assert.NotNil(t, err)
+	assert.NotEqual(t, io.EOF, err)
+
+	_, err2 := gc.Next()
+	assert.Equal(t, err, err2)
+}
+
+const excludeDatesICS = `BEGIN:VCALENDAR
+BEGIN:VEVENT
+UID:exdate@gocal
+DTSTAMP:20151116T133227Z
+DTSTART;TZID=America/New_York:20190101T233000
+DTEND;TZID=America/New_York:20190101T234500
+SUMMARY:Late call
+RRULE:FREQ=DAILY;COUNT=7
+EXDATE:20190102T043000Z,20190103T043000Z
+EXDATE;VALUE=DATE:20190104
+EXDATE:20190106T043000Z,notadate
+END:VEVENT
+END:VCALENDAR`
+
+func Test_ExcludeDates(t *testing.T) {
+	start, end := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2019, 1, 31, 0, 0, 0, 0, time.UTC)
+
+	gc := NewParser(strings.NewReader(excludeDatesICS))
+	gc.Start, gc.End = &start, &end
+	err := gc.Parse()
+
+	assert.Nil(t, err)
+
+	ny, _ := time.LoadLocation("America/New_York")
+	days := make([]int, 0)
+	for _, e := range gc.Events {
+		assert.Equal(t, ny.String(), e.Start.Location().String())
+		days = append(days, e.Start.Day())
+	}
+
+	// The date-only EXDATE removes the occurrence of January 4th in New York, which starts on the 5th in UTC
+	assert.Equal(t, []int{3, 6, 7}, days)
+
+	assert.Len(t, gc.Warnings, 1)
+	assert.Equal(t, SeverityWarning, gc.Warnings[0].Severity)
+	assert.Equal(t, "EXDATE", gc.Warnings[0].Property)
+	assert.Equal(t, 11, gc.Warnings[0].Line)
+}
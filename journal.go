@@ -0,0 +1,162 @@
+package gocal
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func (gc *Gocal) parseJournal(l *Line) error {
+	// If this is nil, that means we did not get a BEGIN:VJOURNAL
+	if gc.journalBuffer == nil {
+		return nil
+	}
+
+	switch l.Key {
+	case "UID":
+		if err := resolve(gc, l, &gc.journalBuffer.Uid, resolveString, nil); err != nil {
+			return err
+		}
+	case "SUMMARY":
+		if err := resolve(gc, l, &gc.journalBuffer.Summary, resolveString, nil); err != nil {
+			return err
+		}
+	case "DESCRIPTION":
+		// Journals are the only components allowed to have several descriptions
+		gc.journalBuffer.Descriptions = append(gc.journalBuffer.Descriptions, l.Value)
+	case "DTSTART":
+		if err := resolve(gc, l, &gc.journalBuffer.Start, resolveDate, func(gc *Gocal, out *time.Time) {
+			gc.journalBuffer.RawStart = RawDate{Value: l.Value, Params: l.Params}
+		}); err != nil {
+			return err
+		}
+	case "DTSTAMP":
+		if err := resolve(gc, l, &gc.journalBuffer.Stamp, resolveDate, nil); err != nil {
+			return err
+		}
+	case "CREATED":
+		if err := resolve(gc, l, &gc.journalBuffer.Created, resolveDate, nil); err != nil {
+			return err
+		}
+	case "LAST-MODIFIED":
+		if err := resolve(gc, l, &gc.journalBuffer.LastModified, resolveDate, nil); err != nil {
+			return err
+		}
+	case "RRULE":
+		if err := resolve(gc, l, &gc.journalBuffer.RecurrenceRule, resolveRRule, func(gc *Gocal, _ *RRule) {
+			gc.journalBuffer.IsRecurring = true
+		}); err != nil {
+			return err
+		}
+	case "EXDATE":
+		dates, days := gc.parseExcludeDates(l)
+		gc.journalBuffer.ExcludeDates = append(gc.journalBuffer.ExcludeDates, dates...)
+		gc.journalBuffer.ExcludeDays = append(gc.journalBuffer.ExcludeDays, days...)
+	case "SEQUENCE":
+		gc.journalBuffer.Sequence, _ = strconv.Atoi(l.Value)
+	case "STATUS":
+		if err := resolve(gc, l, &gc.journalBuffer.Status, resolveString, nil); err != nil {
+			return err
+		}
+	case "ORGANIZER":
+		if err := resolve(gc, l, &gc.journalBuffer.Organizer, resolveOrganizer, nil); err != nil {
+			return err
+		}
+	case "ATTENDEE":
+		gc.journalBuffer.Attendees = append(gc.journalBuffer.Attendees, parseAttendee(l))
+	case "ATTACH":
+		gc.journalBuffer.Attachments = append(gc.journalBuffer.Attachments, parseAttachment(l))
+	case "CATEGORIES":
+		gc.journalBuffer.Categories = append(gc.journalBuffer.Categories, strings.Split(l.Value, ",")...)
+	case "URL":
+		gc.journalBuffer.URL = l.Value
+	case "CLASS":
+		gc.journalBuffer.Class = l.Value
+	default:
+		key := strings.ToUpper(l.Key)
+		if strings.HasPrefix(key, "X-") {
+			if gc.journalBuffer.CustomAttributes == nil {
+				gc.journalBuffer.CustomAttributes = make(map[string]string)
+			}
+			gc.journalBuffer.CustomAttributes[key] = l.Value
+		}
+	}
+
+	return nil
+}
+
+func (gc *Gocal) finalizeJournal() error {
+	if err := gc.checkJournal(); err != nil {
+		switch gc.Strict.Mode {
+		case StrictModeFailFeed:
+			return err
+		case StrictModeFailEvent:
+			gc.warn(SeverityError, err)
+			return nil
+		}
+		gc.warn(SeverityError, err)
+	}
+
+	if gc.Strict.Mode == StrictModeFailEvent && !gc.journalBuffer.Valid {
+		return nil
+	}
+
+	if gc.journalBuffer.IsRecurring && gc.journalBuffer.Start != nil {
+		gc.Journals = append(gc.Journals, gc.ExpandRecurringJournal(gc.journalBuffer)...)
+		return nil
+	}
+
+	if !gc.SkipBounds && !gc.IsJournalInRange(*gc.journalBuffer) {
+		return nil
+	}
+
+	gc.Journals = append(gc.Journals, *gc.journalBuffer)
+
+	return nil
+}
+
+func (gc *Gocal) checkJournal() error {
+	if gc.journalBuffer.Uid == "" {
+		gc.journalBuffer.Valid = false
+		return fmt.Errorf("could not parse journal without UID")
+	}
+	if gc.journalBuffer.Stamp == nil {
+		gc.journalBuffer.Valid = false
+		return fmt.Errorf("could not parse journal without DTSTAMP")
+	}
+
+	return nil
+}
+
+// IsJournalInRange checks a journal against the parsing range, using its DTSTART.
+// Journals without DTSTART are not bound to a date and are always in range.
+func (gc *Gocal) IsJournalInRange(j Journal) bool {
+	if j.Start == nil {
+		return true
+	}
+
+	return !j.Start.Before(*gc.Start) && !j.Start.After(*gc.End)
+}
+
+// ExpandRecurringJournal expands a recurring journal the same way as events.
+func (gc *Gocal) ExpandRecurringJournal(buf *Journal) []Journal {
+	instances := gc.ExpandRecurringEvent(&Event{
+		Uid:            buf.Uid,
+		Start:          buf.Start,
+		End:            buf.Start,
+		RecurrenceRule: []RRule{*buf.RecurrenceRule},
+		ExcludeDates:   buf.ExcludeDates,
+		ExcludeDays:    buf.ExcludeDays,
+	})
+
+	journals := make([]Journal, 0, len(instances))
+	for _, i := range instances {
+		j := *buf
+		j.Start = i.Start
+
+		journals = append(journals, j)
+	}
+
+	return journals
+}
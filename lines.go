@@ -0,0 +1,81 @@
+package gocal
+
+import (
+	"strings"
+)
+
+// Lines holds raw content lines kept in lossless mode, in the order they were read.
+type Lines []*Line
+
+func newRawLine(l *Line, raw string) *Line {
+	params := make(map[string]string, len(l.Params))
+	for k, v := range l.Params {
+		params[k] = v
+	}
+
+	l.raw = raw
+	l.original = &Line{Key: l.Key, Params: params, Value: l.Value}
+
+	return l
+}
+
+// IsModified reports if a line was changed since it was parsed, or was not parsed at all.
+func (l *Line) IsModified() bool {
+	if l.original == nil {
+		return true
+	}
+	if l.Key != l.original.Key || l.Value != l.original.Value || len(l.Params) != len(l.original.Params) {
+		return true
+	}
+	for k, v := range l.Params {
+		if ov, ok := l.original.Params[k]; !ok || ov != v {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Get returns the first property with the given key, ignoring properties of nested components.
+// If the first line opens a component, properties are looked up in that component.
+func (ls Lines) Get(key string) *Line {
+	if found := ls.findAll(key, 1); len(found) > 0 {
+		return found[0]
+	}
+	return nil
+}
+
+// GetAll returns all properties with the given key, ignoring properties of nested components.
+func (ls Lines) GetAll(key string) []*Line {
+	return ls.findAll(key, -1)
+}
+
+func (ls Lines) findAll(key string, limit int) []*Line {
+	found := make([]*Line, 0)
+	lines := ls
+
+	if len(lines) > 0 && lines[0].IsKey("BEGIN") {
+		lines = lines[1:]
+	}
+
+	depth := 0
+	for _, l := range lines {
+		switch {
+		case l.IsKey("BEGIN"):
+			depth++
+		case l.IsKey("END"):
+			depth--
+		case depth == 0 && strings.EqualFold(strings.TrimSpace(l.Key), key):
+			found = append(found, l)
+			if limit > 0 && len(found) == limit {
+				return found
+			}
+		}
+
+		if depth < 0 {
+			break
+		}
+	}
+
+	return found
+}
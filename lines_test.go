@@ -0,0 +1,80 @@
+package gocal
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const losslessICS = "BEGIN:VCALENDAR\r\n" +
+	"PRODID:-//Example//Example//EN\r\n" +
+	"X-WR-CALNAME:Rooms\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"UID:0001@example.net\r\n" +
+	"DTSTAMP:20151116T133227Z\r\n" +
+	"DTSTART:20190101T090000Z\r\n" +
+	"DTEND:20190101T100000Z\r\n" +
+	"SUMMARY:Original summary\r\n" +
+	"DESCRIPTION:Amazing description on t\r\n" +
+	" wo lines\\, escaped\r\n" +
+	"TRANSP:TRANSPARENT\r\n" +
+	"RESOURCES;LANGUAGE=en:Projector\r\n" +
+	"BEGIN:VALARM\r\n" +
+	"ACTION:DISPLAY\r\n" +
+	"SUMMARY:Alarm summary\r\n" +
+	"TRIGGER:-PT15M\r\n" +
+	"END:VALARM\r\n" +
+	"END:VEVENT\r\n" +
+	"\r\n" +
+	"BEGIN:X-UNKNOWN\n" +
+	"FOO;BAR=baz:qux\n" +
+	"END:X-UNKNOWN\n" +
+	"END:VCALENDAR"
+
+func Test_LosslessRoundTrip(t *testing.T) {
+	start, end := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	gc := NewParser(strings.NewReader(losslessICS))
+	gc.Start, gc.End = &start, &end
+	gc.Lossless = true
+	err := gc.Parse()
+
+	assert.Nil(t, err)
+	assert.Len(t, gc.Events, 1)
+	assert.Len(t, gc.Events[0].Lines, 15)
+	assert.Equal(t, "X-WR-CALNAME", gc.Lines.Get("X-WR-CALNAME").Key)
+	assert.Equal(t, "Projector", gc.Events[0].Lines.Get("RESOURCES").Value)
+	assert.Equal(t, "en", gc.Events[0].Lines.Get("RESOURCES").Params["LANGUAGE"])
+	assert.Equal(t, "Original summary", gc.Events[0].Lines.Get("SUMMARY").Value)
+	assert.Nil(t, gc.Lines.Get("SUMMARY"))
+
+	var buf bytes.Buffer
+	err = NewEncoder(&buf).EncodeLines(gc.Lines)
+
+	assert.Nil(t, err)
+	assert.Equal(t, losslessICS, buf.String())
+
+	gc.Events[0].Lines.Get("SUMMARY").Value = "Changed, summary"
+
+	buf.Reset()
+	err = NewEncoder(&buf).EncodeLines(gc.Lines)
+
+	assert.Nil(t, err)
+	assert.Equal(t, strings.Replace(losslessICS, "SUMMARY:Original summary", `SUMMARY:Changed\, summary`, 1), buf.String())
+}
+
+func Test_LinesGetAll(t *testing.T) {
+	gc := NewParser(strings.NewReader(losslessICS))
+	gc.Lossless = true
+	gc.SkipBounds = true
+	gc.Parse()
+
+	assert.Len(t, gc.Lines, 22)
+	assert.Len(t, gc.Events[0].Lines.GetAll("SUMMARY"), 1)
+	assert.Len(t, gc.Lines.GetAll("PRODID"), 1)
+	assert.False(t, gc.Lines[0].IsModified())
+	assert.True(t, (&Line{Key: "SUMMARY"}).IsModified())
+}
@@ -2,6 +2,7 @@ package parser
 
 import (
 	"strings"
+	"unicode/utf8"
 )
 
 func ParseRecurrenceParams(p string) (string, map[string]string) {
@@ -36,10 +37,76 @@ func ParseParameters(p string) (string, map[string]string) {
 	return tokens[0], parameters
 }
 
+// UnescapeString unescapes backslashes, semicolons and commas in a value.
 func UnescapeString(l string) string {
-	l = strings.Replace(l, `\\`, `\`, -1)
-	l = strings.Replace(l, `\;`, `;`, -1)
-	l = strings.Replace(l, `\,`, `,`, -1)
+	return unescape(l, false)
+}
+
+// UnescapeText unescapes a TEXT value, in which \n and \N are also line breaks.
+// Reference: https://icalendar.org/iCalendar-RFC-5545/3-3-11-text.html
+func UnescapeText(l string) string {
+	return unescape(l, true)
+}
+
+func unescape(l string, text bool) string {
+	if !strings.Contains(l, `\`) {
+		return l
+	}
+
+	var sb strings.Builder
+	sb.Grow(len(l))
+
+	for i := 0; i < len(l); i++ {
+		if l[i] != '\\' || i+1 == len(l) {
+			sb.WriteByte(l[i])
+			continue
+		}
+
+		switch l[i+1] {
+		case '\\', ';', ',':
+			sb.WriteByte(l[i+1])
+		case 'n', 'N':
+			if !text {
+				sb.WriteByte(l[i])
+				continue
+			}
+			sb.WriteByte('\n')
+		default:
+			sb.WriteByte(l[i])
+			continue
+		}
+
+		i++
+	}
+
+	return sb.String()
+}
+
+// EscapeString escapes a TEXT value so it can be written to a content line.
+// Reference: https://icalendar.org/iCalendar-RFC-5545/3-3-11-text.html
+func EscapeString(l string) string {
+	return textEscaper.Replace(l)
+}
+
+var textEscaper = strings.NewReplacer(`\`, `\\`, `;`, `\;`, `,`, `\,`, "\r\n", `\n`, "\n", `\n`)
+
+// FoldLine splits a content line into lines of at most 75 octets, without breaking UTF-8 sequences.
+// Continuation lines start with a single space.
+// Reference: https://icalendar.org/iCalendar-RFC-5545/3-1-content-lines.html
+func FoldLine(l string) []string {
+	lines := make([]string, 0, len(l)/74+1)
+	prefix, limit := "", 75
+
+	for len(l) > limit {
+		idx := limit
+		for idx > 0 && !utf8.RuneStart(l[idx]) {
+			idx--
+		}
+
+		lines = append(lines, prefix+l[:idx])
+		l = l[idx:]
+		prefix, limit = " ", 74
+	}
 
-	return l
+	return append(lines, prefix+l)
 }
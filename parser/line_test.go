@@ -1,7 +1,9 @@
 package parser
 
 import (
+	"strings"
 	"testing"
+	"unicode/utf8"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -20,3 +22,44 @@ func Test_UnescapeString(t *testing.T) {
 
 	assert.Equal(t, `Hello, world; lorem \ipsum.`, l)
 }
+
+func Test_UnescapeStringNewlines(t *testing.T) {
+	// Line breaks are only unescaped in TEXT values
+	assert.Equal(t, `Line one\nLine two\NLine three`, UnescapeString(`Line one\nLine two\NLine three`))
+	assert.Equal(t, `C:\new`, UnescapeString(`C:\\new`))
+
+	assert.Equal(t, "Line one\nLine two\nLine three", UnescapeText(`Line one\nLine two\NLine three`))
+	assert.Equal(t, `C:\new`, UnescapeText(`C:\\new`))
+	assert.Equal(t, `C:\path`, UnescapeText(`C:\path`))
+	assert.Equal(t, `trailing\`, UnescapeText(`trailing\`))
+}
+
+func Test_EscapeString(t *testing.T) {
+	l := "Hello, world; lorem \\ipsum.\nNew line"
+
+	assert.Equal(t, `Hello\, world\; lorem \\ipsum.\nNew line`, EscapeString(l))
+	assert.Equal(t, l, UnescapeText(EscapeString(l)))
+}
+
+func Test_FoldLine(t *testing.T) {
+	assert.Equal(t, []string{"SUMMARY:short"}, FoldLine("SUMMARY:short"))
+
+	l := "DESCRIPTION:" + strings.Repeat("a", 150)
+	lines := FoldLine(l)
+
+	assert.Len(t, lines, 3)
+	assert.Len(t, lines[0], 75)
+	assert.Len(t, lines[1], 75)
+	assert.Equal(t, " ", lines[1][:1])
+	assert.Equal(t, l, lines[0]+lines[1][1:]+lines[2][1:])
+
+	// Multi-byte characters are not split across lines
+	l = "SUMMARY:" + strings.Repeat("é", 60)
+	lines = FoldLine(l)
+
+	for _, line := range lines {
+		assert.True(t, len(line) <= 75)
+		assert.True(t, utf8.ValidString(line))
+	}
+	assert.Equal(t, l, lines[0]+lines[1][1:])
+}
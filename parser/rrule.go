@@ -1,5 +1,8 @@
 package parser
 
+// ParseRecurrenceRule splits a recurrence rule into its parts, without validating them.
+//
+// Deprecated: use gocal.ParseRRule, which returns a typed and validated gocal.RRule.
 func ParseRecurrenceRule(v string) (map[string]string, error) {
 	_, params := ParseRecurrenceParams(v)
 
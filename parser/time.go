@@ -50,14 +50,7 @@ func ParseTime(s string, params map[string]string, ty int, allday bool, allDayTZ
 
 		// If TZID param is given, parse in the timezone unless it is not valid
 		format = "20060102T150405"
-		if TZMapper != nil {
-			tz, err = TZMapper(params["TZID"])
-		}
-		if TZMapper == nil || err != nil {
-			tz, err = LoadTimezone(params["TZID"])
-		}
-
-		if err != nil {
+		if tz, err = LoadLocation(params["TZID"]); err != nil {
 			tz, _ = time.LoadLocation("UTC")
 		}
 	} else {
@@ -72,14 +65,31 @@ func ParseTime(s string, params map[string]string, ty int, allday bool, allDayTZ
 }
 
 func ParseDuration(s string) (*time.Duration, error) {
-	d, err := duration.FromString(s)
+	// Durations can be signed (as in alarm triggers), which is not handled by the underlying library
+	sign := time.Duration(1)
+	if strings.HasPrefix(s, "-") {
+		sign = -1
+	}
+
+	d, err := duration.FromString(strings.TrimLeft(s, "+-"))
 	if err != nil {
 		return nil, err
 	}
-	dur := d.ToDuration()
+	dur := sign * d.ToDuration()
 	return &dur, nil
 }
 
+// LoadLocation resolves a TZID through the TZMapper callback, if any, and then the system timezone database.
+func LoadLocation(tzid string) (*time.Location, error) {
+	if TZMapper != nil {
+		if tz, err := TZMapper(tzid); err == nil {
+			return tz, nil
+		}
+	}
+
+	return LoadTimezone(tzid)
+}
+
 func LoadTimezone(tzid string) (*time.Location, error) {
 	tz, err := time.LoadLocation(tzid)
 	if err == nil {
@@ -145,3 +145,21 @@ func Test_ParseTimeAllDayOtherTimezone(t *testing.T) {
 	assert.Equal(t, 59, tiz.Minute())
 	assert.Equal(t, 59, tiz.Second())
 }
+
+func Test_ParseDuration(t *testing.T) {
+	data := map[string]time.Duration{
+		"PT15M":     15 * time.Minute,
+		"+PT1H":     time.Hour,
+		"-PT15M":    -15 * time.Minute,
+		"-P1DT2H":   -26 * time.Hour,
+		"P2W":       14 * 24 * time.Hour,
+		"P1DT1H10M": 24*time.Hour + 70*time.Minute,
+	}
+
+	for in, exp := range data {
+		d, err := ParseDuration(in)
+
+		assert.Nil(t, err)
+		assert.Equal(t, exp, *d)
+	}
+}
@@ -0,0 +1,171 @@
+package parser
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+)
+
+type Zone struct {
+	Name   string
+	Offset int
+	DST    bool
+}
+
+type ZoneTransition struct {
+	When time.Time
+	Zone Zone
+}
+
+// ParseUTCOffset parses an UTC offset (as in TZOFFSETFROM and TZOFFSETTO) into a number of seconds.
+// Reference: https://icalendar.org/iCalendar-RFC-5545/3-3-14-utc-offset.html
+func ParseUTCOffset(s string) (int, error) {
+	if len(s) != 5 && len(s) != 7 {
+		return 0, fmt.Errorf("could not parse UTC offset: %s", s)
+	}
+
+	sign := 1
+	switch s[0] {
+	case '+':
+	case '-':
+		sign = -1
+	default:
+		return 0, fmt.Errorf("could not parse UTC offset: %s", s)
+	}
+
+	offset := 0
+	for idx, unit := range []int{3600, 60, 1} {
+		if 1+idx*2 >= len(s) {
+			break
+		}
+
+		v, err := strconv.Atoi(s[1+idx*2 : 3+idx*2])
+		if err != nil {
+			return 0, fmt.Errorf("could not parse UTC offset: %s", s)
+		}
+
+		offset += v * unit
+	}
+
+	return sign * offset, nil
+}
+
+// FormatUTCOffset formats a number of seconds as an UTC offset, the seconds being only written when not 0.
+func FormatUTCOffset(offset int) string {
+	sign := '+'
+	if offset < 0 {
+		sign, offset = '-', -offset
+	}
+
+	s := fmt.Sprintf("%c%02d%02d", sign, offset/3600, offset%3600/60)
+	if offset%60 != 0 {
+		s += fmt.Sprintf("%02d", offset%60)
+	}
+
+	return s
+}
+
+// NewLocation builds a *time.Location from a list of transitions, as described by a VTIMEZONE component.
+// The initial zone is used for any instant before the first transition.
+//
+// Go does not provide a way to build a location with transitions, so we go through
+// a generated TZif (version 2) blob that is fed to time.LoadLocationFromTZData().
+func NewLocation(name string, initial Zone, transitions []ZoneTransition) (*time.Location, error) {
+	transitions = append([]ZoneTransition{}, transitions...)
+	sort.SliceStable(transitions, func(i, j int) bool {
+		return transitions[i].When.Before(transitions[j].When)
+	})
+
+	zones := []Zone{initial}
+	indices := make([]byte, 0, len(transitions))
+
+	for _, t := range transitions {
+		idx := -1
+		for i, z := range zones {
+			if z == t.Zone {
+				idx = i
+				break
+			}
+		}
+		if idx < 0 {
+			if len(zones) == 255 {
+				return nil, fmt.Errorf("too many zones in timezone %s", name)
+			}
+
+			zones = append(zones, t.Zone)
+			idx = len(zones) - 1
+		}
+
+		indices = append(indices, byte(idx))
+	}
+
+	abbrevs := make([]byte, 0)
+	abbrevIndices := make([]int, len(zones))
+	for i, z := range zones {
+		abbr := z.Name
+		if abbr == "" {
+			abbr = formatOffset(z.Offset)
+		}
+
+		if idx := bytes.Index(abbrevs, append([]byte(abbr), 0)); idx >= 0 {
+			abbrevIndices[i] = idx
+			continue
+		}
+
+		abbrevIndices[i] = len(abbrevs)
+		abbrevs = append(abbrevs, abbr...)
+		abbrevs = append(abbrevs, 0)
+	}
+
+	var buf bytes.Buffer
+
+	header := func(timecnt, typecnt, charcnt int) {
+		buf.WriteString("TZif2")
+		buf.Write(make([]byte, 15))
+		for _, n := range []int{0, 0, 0, timecnt, typecnt, charcnt} {
+			binary.Write(&buf, binary.BigEndian, uint32(n))
+		}
+	}
+
+	// Version 1 data block, empty since the version 2 block supersedes it
+	header(0, 1, 1)
+	buf.Write([]byte{0, 0, 0, 0, 0, 0})
+	buf.WriteByte(0)
+
+	// Version 2 data block with 64-bit transition times
+	header(len(transitions), len(zones), len(abbrevs))
+	for _, t := range transitions {
+		binary.Write(&buf, binary.BigEndian, t.When.Unix())
+	}
+	buf.Write(indices)
+	for i, z := range zones {
+		binary.Write(&buf, binary.BigEndian, int32(z.Offset))
+		if z.DST {
+			buf.WriteByte(1)
+		} else {
+			buf.WriteByte(0)
+		}
+		buf.WriteByte(byte(abbrevIndices[i]))
+	}
+	buf.Write(abbrevs)
+	buf.WriteString("\n\n")
+
+	return time.LoadLocationFromTZData(name, buf.Bytes())
+}
+
+func formatOffset(offset int) string {
+	sign := '+'
+	if offset < 0 {
+		sign = '-'
+		offset = -offset
+	}
+
+	if offset%60 != 0 {
+		return fmt.Sprintf("%c%02d%02d%02d", sign, offset/3600, offset%3600/60, offset%60)
+	}
+
+	return fmt.Sprintf("%c%02d%02d", sign, offset/3600, offset%3600/60)
+}
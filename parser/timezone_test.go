@@ -0,0 +1,69 @@
+package parser
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_ParseUTCOffset(t *testing.T) {
+	data := map[string]int{
+		"+0100":   3600,
+		"-0500":   -18000,
+		"+0530":   19800,
+		"-000130": -90,
+	}
+
+	for in, exp := range data {
+		offset, err := ParseUTCOffset(in)
+
+		assert.Nil(t, err)
+		assert.Equal(t, exp, offset)
+	}
+
+	_, err := ParseUTCOffset("0100")
+	assert.NotNil(t, err)
+
+	_, err = ParseUTCOffset("+01h0")
+	assert.NotNil(t, err)
+}
+
+func Test_FormatUTCOffset(t *testing.T) {
+	data := map[int]string{
+		0:      "+0000",
+		3600:   "+0100",
+		-18000: "-0500",
+		19800:  "+0530",
+		-90:    "-000130",
+	}
+
+	for in, exp := range data {
+		assert.Equal(t, exp, FormatUTCOffset(in))
+	}
+}
+
+func Test_NewLocation(t *testing.T) {
+	standard := Zone{Name: "CET", Offset: 3600}
+	daylight := Zone{Name: "CEST", Offset: 7200, DST: true}
+
+	tz, err := NewLocation("Custom", standard, []ZoneTransition{
+		{When: time.Date(2024, 10, 27, 1, 0, 0, 0, time.UTC), Zone: standard},
+		{When: time.Date(2024, 3, 31, 1, 0, 0, 0, time.UTC), Zone: daylight},
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, "Custom", tz.String())
+
+	name, offset := time.Date(2024, 1, 15, 12, 0, 0, 0, tz).Zone()
+	assert.Equal(t, "CET", name)
+	assert.Equal(t, 3600, offset)
+
+	name, offset = time.Date(2024, 7, 15, 12, 0, 0, 0, tz).Zone()
+	assert.Equal(t, "CEST", name)
+	assert.Equal(t, 7200, offset)
+
+	name, offset = time.Date(2024, 12, 15, 12, 0, 0, 0, tz).Zone()
+	assert.Equal(t, "CET", name)
+	assert.Equal(t, 3600, offset)
+}
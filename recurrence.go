@@ -0,0 +1,201 @@
+package gocal
+
+import (
+	"sort"
+	"time"
+)
+
+// RecurrenceIterator yields the occurrences of a recurrence set in chronological order.
+type RecurrenceIterator struct {
+	rules        []*ruleIterator
+	excludeRules []*ruleIterator
+	dates        []time.Time
+	excluded     map[int64]bool
+	excludedDays map[string]bool
+	loc          *time.Location
+	last         *time.Time
+}
+
+// RecurrenceSet returns the recurrence set of an event, which must have a DTSTART.
+func (e *Event) RecurrenceSet() *RecurrenceSet {
+	set := &RecurrenceSet{
+		Start:        *e.Start,
+		Rules:        e.RecurrenceRule,
+		ExcludeRules: e.ExcludeRules,
+		ExcludeDates: e.ExcludeDates,
+		ExcludeDays:  e.ExcludeDays,
+	}
+
+	for _, rd := range e.RecurrenceDates {
+		set.Dates = append(set.Dates, rd.Start)
+	}
+
+	return set
+}
+
+// Iterator returns an iterator over the occurrences of the set, from the first one.
+func (s *RecurrenceSet) Iterator() *RecurrenceIterator {
+	it := &RecurrenceIterator{excluded: make(map[int64]bool), excludedDays: make(map[string]bool), loc: s.Start.Location()}
+
+	for _, rule := range s.Rules {
+		it.rules = append(it.rules, newRuleIterator(rule, s.Start))
+	}
+	for _, rule := range s.ExcludeRules {
+		it.excludeRules = append(it.excludeRules, newRuleIterator(rule, s.Start))
+	}
+
+	if len(s.Rules) == 0 {
+		it.dates = append(it.dates, s.Start)
+	}
+	it.dates = append(it.dates, s.Dates...)
+	sort.SliceStable(it.dates, func(i, j int) bool { return it.dates[i].Before(it.dates[j]) })
+
+	for _, ex := range s.ExcludeDates {
+		it.excluded[ex.UnixNano()] = true
+	}
+	for _, ex := range s.ExcludeDays {
+		it.excludedDays[ex.Format("20060102")] = true
+	}
+
+	return it
+}
+
+// All lists the first occurrences of the set, up to limit. A limit of 0 lists all of them,
+// and should only be used on sets whose rules are bounded by COUNT or UNTIL.
+func (s *RecurrenceSet) All(limit int) []time.Time {
+	occurrences := make([]time.Time, 0)
+
+	it := s.Iterator()
+	for limit <= 0 || len(occurrences) < limit {
+		o, ok := it.Next()
+		if !ok {
+			break
+		}
+		occurrences = append(occurrences, o)
+	}
+
+	return occurrences
+}
+
+// Between lists the occurrences of the set starting between a and b, inclusive.
+func (s *RecurrenceSet) Between(a, b time.Time) []time.Time {
+	occurrences := make([]time.Time, 0)
+
+	it := s.Iterator()
+	it.bound(a, b)
+	for {
+		o, ok := it.Next()
+		if !ok || o.After(b) {
+			break
+		}
+		if !o.Before(a) {
+			occurrences = append(occurrences, o)
+		}
+	}
+
+	return occurrences
+}
+
+// After returns the first occurrence of the set strictly after t, if any.
+func (s *RecurrenceSet) After(t time.Time) *time.Time {
+	it := s.Iterator()
+	it.bound(t, time.Time{})
+	for {
+		o, ok := it.Next()
+		if !ok {
+			return nil
+		}
+		if o.After(t) {
+			return &o
+		}
+	}
+}
+
+// Before returns the last occurrence of the set strictly before t, if any.
+func (s *RecurrenceSet) Before(t time.Time) *time.Time {
+	var last *time.Time
+
+	it := s.Iterator()
+	it.bound(time.Time{}, t)
+	for {
+		o, ok := it.Next()
+		if !ok || !o.Before(t) {
+			return last
+		}
+		last = &o
+	}
+}
+
+// Next returns the next occurrence of the set, or false when there are none left.
+func (it *RecurrenceIterator) Next() (time.Time, bool) {
+	for {
+		var next *time.Time
+
+		for _, rule := range it.rules {
+			if o, ok := rule.peek(); ok && (next == nil || o.Before(*next)) {
+				next = &o
+			}
+		}
+		if len(it.dates) > 0 && (next == nil || it.dates[0].Before(*next)) {
+			next = &it.dates[0]
+		}
+		if next == nil {
+			return time.Time{}, false
+		}
+
+		o := *next
+
+		// Occurrences produced by several rules or dates are only yielded once
+		for _, rule := range it.rules {
+			if p, ok := rule.peek(); ok && p.Equal(o) {
+				rule.next()
+			}
+		}
+		for len(it.dates) > 0 && it.dates[0].Equal(o) {
+			it.dates = it.dates[1:]
+		}
+
+		if it.last != nil && it.last.Equal(o) {
+			continue
+		}
+		it.last = &o
+
+		if it.excluded[o.UnixNano()] || it.excludedDays[o.In(it.loc).Format("20060102")] || it.excludedByRule(o) {
+			continue
+		}
+
+		return o, true
+	}
+}
+
+// excludedByRule checks an occurrence against the EXRULEs, moving them up to it.
+func (it *RecurrenceIterator) excludedByRule(o time.Time) bool {
+	excluded := false
+
+	for _, rule := range it.excludeRules {
+		for {
+			ex, ok := rule.peek()
+			if !ok || ex.After(o) {
+				break
+			}
+			if ex.Equal(o) {
+				excluded = true
+			}
+			rule.next()
+		}
+	}
+
+	return excluded
+}
+
+// bound restricts the rules of the iterator to occurrences around the given range, a zero time leaving it open.
+func (it *RecurrenceIterator) bound(from, to time.Time) {
+	for _, rule := range append(append([]*ruleIterator{}, it.rules...), it.excludeRules...) {
+		if !from.IsZero() {
+			rule.skipTo(from)
+		}
+		if !to.IsZero() {
+			rule.limit = &to
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package gocal
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func formatDates(dates []time.Time) []string {
+	formatted := make([]string, 0, len(dates))
+	for _, d := range dates {
+		formatted = append(formatted, d.Format("2006-01-02 15:04"))
+	}
+
+	return formatted
+}
+
+func Test_RecurrenceSet(t *testing.T) {
+	weekly, _ := ParseRRule("FREQ=WEEKLY;BYDAY=MO,WE")
+	monthly, _ := ParseRRule("FREQ=MONTHLY;BYMONTHDAY=1")
+
+	set := &RecurrenceSet{
+		Start:        time.Date(2019, 1, 7, 9, 0, 0, 0, time.UTC),
+		Rules:        []RRule{*weekly},
+		ExcludeRules: []RRule{*monthly},
+		Dates:        []time.Time{time.Date(2019, 1, 10, 14, 0, 0, 0, time.UTC), time.Date(2019, 1, 9, 9, 0, 0, 0, time.UTC)},
+		ExcludeDates: []time.Time{time.Date(2019, 1, 14, 9, 0, 0, 0, time.UTC)},
+	}
+
+	assert.Equal(t, []string{"2019-01-07 09:00", "2019-01-09 09:00", "2019-01-10 14:00", "2019-01-16 09:00", "2019-01-21 09:00"}, formatDates(set.All(5)))
+
+	between := set.Between(time.Date(2019, 1, 28, 0, 0, 0, 0, time.UTC), time.Date(2019, 4, 3, 9, 0, 0, 0, time.UTC))
+	assert.Len(t, between, 19)
+	assert.Equal(t, "2019-01-28 09:00", formatDates(between)[0])
+	assert.Equal(t, "2019-04-03 09:00", formatDates(between)[18])
+	assert.NotContains(t, formatDates(between), "2019-04-01 09:00")
+
+	after := set.After(time.Date(2019, 1, 9, 9, 0, 0, 0, time.UTC))
+	assert.Equal(t, time.Date(2019, 1, 10, 14, 0, 0, 0, time.UTC), *after)
+
+	before := set.Before(time.Date(2019, 1, 16, 9, 0, 0, 0, time.UTC))
+	assert.Equal(t, time.Date(2019, 1, 10, 14, 0, 0, 0, time.UTC), *before)
+
+	assert.Nil(t, set.Before(set.Start))
+}
+
+func Test_RecurrenceSetBounded(t *testing.T) {
+	rule, _ := ParseRRule("FREQ=DAILY;COUNT=3")
+	set := &RecurrenceSet{Start: time.Date(2019, 1, 1, 9, 0, 0, 0, time.UTC), Rules: []RRule{*rule}}
+
+	assert.Equal(t, []string{"2019-01-01 09:00", "2019-01-02 09:00", "2019-01-03 09:00"}, formatDates(set.All(0)))
+	assert.Nil(t, set.After(time.Date(2019, 1, 3, 9, 0, 0, 0, time.UTC)))
+
+	// Without any rule, DTSTART and RDATEs are the only occurrences
+	set = &RecurrenceSet{Start: time.Date(2019, 1, 1, 9, 0, 0, 0, time.UTC), Dates: []time.Time{time.Date(2019, 1, 1, 9, 0, 0, 0, time.UTC), time.Date(2019, 2, 1, 9, 0, 0, 0, time.UTC)}}
+
+	assert.Equal(t, []string{"2019-01-01 09:00", "2019-02-01 09:00"}, formatDates(set.All(0)))
+
+	// Rules that never match do not loop forever
+	rule, _ = ParseRRule("FREQ=YEARLY;BYMONTH=2;BYMONTHDAY=30")
+	set = &RecurrenceSet{Start: time.Date(2019, 1, 1, 9, 0, 0, 0, time.UTC), Rules: []RRule{*rule}}
+
+	assert.Nil(t, set.After(set.Start))
+}
+
+func Test_RecurrenceIterator(t *testing.T) {
+	rule, _ := ParseRRule("FREQ=MINUTELY;INTERVAL=20;BYHOUR=9")
+	set := &RecurrenceSet{Start: time.Date(2019, 1, 1, 9, 0, 0, 0, time.UTC), Rules: []RRule{*rule}}
+
+	it := set.Iterator()
+
+	dates := make([]time.Time, 0)
+	for len(dates) < 5 {
+		o, ok := it.Next()
+		if !ok {
+			break
+		}
+		dates = append(dates, o)
+	}
+
+	assert.Equal(t, []string{"2019-01-01 09:00", "2019-01-01 09:20", "2019-01-01 09:40", "2019-01-02 09:00", "2019-01-02 09:20"}, formatDates(dates))
+}
+
+func Test_RecurrenceSetNeverMatching(t *testing.T) {
+	start := time.Date(2019, 1, 1, 9, 0, 0, 0, time.UTC)
+
+	// Leap seconds are taken as the last second of the minute
+	leap, _ := ParseRRule("FREQ=SECONDLY;BYSECOND=60")
+	set := &RecurrenceSet{Start: start, Rules: []RRule{*leap}}
+	assert.Equal(t, []time.Time{start.Add(59 * time.Second), start.Add(119 * time.Second)}, set.All(2))
+
+	rules := []string{"FREQ=DAILY;BYMONTH=2;BYMONTHDAY=30", "FREQ=SECONDLY;INTERVAL=2;BYSECOND=1", "FREQ=MONTHLY;BYDAY=MO;BYSETPOS=6"}
+
+	for _, rule := range rules {
+		t.Run(rule, func(t *testing.T) {
+			rrule, _ := ParseRRule(rule)
+			set := &RecurrenceSet{Start: start, Rules: []RRule{*rrule}}
+
+			started := time.Now()
+			assert.Nil(t, set.After(start))
+			assert.Empty(t, set.All(0))
+			assert.True(t, time.Since(started) < time.Second)
+		})
+	}
+}
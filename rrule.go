@@ -2,169 +2,966 @@ package gocal
 
 import (
 	"fmt"
+	"sort"
 	"strconv"
 	"strings"
-
-	"github.com/apognu/gocal/parser"
+	"time"
 )
 
+// YmdHis was used to compare recurrence dates as strings.
+//
+// Deprecated: recurrences are expanded on time.Time values, see RRule.
 const YmdHis = "2006-01-02 15:04:05"
 
+// Rules producing no occurrence are not expanded past that year
+const maxRecurrenceYear = 9999
+
+// Rules are not expanded further after that many consecutive periods without occurrences, as they likely never match
+const maxEmptyPeriods = 20000
+
+const (
+	untilLayoutDate     = "20060102"
+	untilLayoutFloating = "20060102T150405"
+	untilLayoutUTC      = "20060102T150405Z"
+)
+
+var icsDayNames = map[time.Weekday]string{
+	time.Monday:    "MO",
+	time.Tuesday:   "TU",
+	time.Wednesday: "WE",
+	time.Thursday:  "TH",
+	time.Friday:    "FR",
+	time.Saturday:  "SA",
+	time.Sunday:    "SU",
+}
+
+// recurrence holds the parts of a recurrence rule selecting occurrences within a period.
+type recurrence struct {
+	RRule
+	start time.Time
+}
+
+// ParseRRule parses a recurrence rule, such as FREQ=MONTHLY;BYDAY=2TU, and validates it against RFC 5545.
+// Unknown X- parts are ignored.
+func ParseRRule(v string) (*RRule, error) {
+	r := &RRule{Interval: 1, WeekStart: time.Monday}
+	seen := make(map[string]bool)
+
+	for _, part := range strings.Split(v, ";") {
+		// Some producers end rules with a semicolon
+		if part == "" {
+			continue
+		}
+
+		tokens := strings.SplitN(part, "=", 2)
+		if len(tokens) != 2 || tokens[1] == "" {
+			return nil, NewRRuleError(v, "malformed part %q", part)
+		}
+
+		name, value := strings.ToUpper(tokens[0]), strings.ToUpper(tokens[1])
+		if seen[name] {
+			return nil, NewRRuleError(v, "duplicate part %s", name)
+		}
+		seen[name] = true
+
+		var err error
+		switch name {
+		case "FREQ":
+			r.Freq = value
+		case "UNTIL":
+			err = r.parseUntil(value)
+		case "COUNT":
+			r.Count, err = strconv.Atoi(value)
+		case "INTERVAL":
+			r.Interval, err = strconv.Atoi(value)
+		case "BYSECOND":
+			r.BySecond, err = parseRuleInts(value)
+		case "BYMINUTE":
+			r.ByMinute, err = parseRuleInts(value)
+		case "BYHOUR":
+			r.ByHour, err = parseRuleInts(value)
+		case "BYDAY":
+			r.ByDay, err = parseByDay(value)
+		case "BYMONTHDAY":
+			r.ByMonthDay, err = parseRuleInts(value)
+		case "BYYEARDAY":
+			r.ByYearDay, err = parseRuleInts(value)
+		case "BYWEEKNO":
+			r.ByWeekNo, err = parseRuleInts(value)
+		case "BYMONTH":
+			r.ByMonth, err = parseRuleInts(value)
+		case "BYSETPOS":
+			r.BySetPos, err = parseRuleInts(value)
+		case "WKST":
+			day, ok := parseIcsDayName(value)
+			if !ok {
+				err = fmt.Errorf("unknown day %s", value)
+			}
+			r.WeekStart = day
+		default:
+			if !strings.HasPrefix(name, "X-") {
+				return nil, NewRRuleError(v, "unknown part %s", name)
+			}
+		}
+
+		if err != nil {
+			return nil, NewRRuleError(v, "invalid %s: %s", name, err)
+		}
+	}
+
+	if seen["COUNT"] && r.Count < 1 {
+		return nil, NewRRuleError(v, "COUNT must be positive")
+	}
+	if seen["INTERVAL"] && r.Interval < 1 {
+		return nil, NewRRuleError(v, "INTERVAL must be positive")
+	}
+
+	if err := r.validate(); err != nil {
+		return nil, NewRRuleError(v, err.Error())
+	}
+
+	return r, nil
+}
+
+// Validate checks a rule against the constraints of RFC 5545.
+func (r RRule) Validate() error {
+	if err := r.validate(); err != nil {
+		return NewRRuleError(r.String(), err.Error())
+	}
+
+	return nil
+}
+
+func (r RRule) validate() error {
+	switch r.Freq {
+	case "SECONDLY", "MINUTELY", "HOURLY", "DAILY", "WEEKLY", "MONTHLY", "YEARLY":
+	case "":
+		return fmt.Errorf("FREQ is required")
+	default:
+		return fmt.Errorf("unknown frequency %s", r.Freq)
+	}
+
+	if r.Interval < 0 {
+		return fmt.Errorf("INTERVAL must be positive")
+	}
+	if r.Count < 0 {
+		return fmt.Errorf("COUNT must be positive")
+	}
+	if r.Count > 0 && r.Until != nil {
+		return fmt.Errorf("COUNT and UNTIL cannot be used together")
+	}
+
+	ranges := []struct {
+		name     string
+		values   []int
+		min, max int
+		negative bool
+	}{
+		{"BYSECOND", r.BySecond, 0, 60, false},
+		{"BYMINUTE", r.ByMinute, 0, 59, false},
+		{"BYHOUR", r.ByHour, 0, 23, false},
+		{"BYMONTHDAY", r.ByMonthDay, 1, 31, true},
+		{"BYYEARDAY", r.ByYearDay, 1, 366, true},
+		{"BYWEEKNO", r.ByWeekNo, 1, 53, true},
+		{"BYMONTH", r.ByMonth, 1, 12, false},
+		{"BYSETPOS", r.BySetPos, 1, 366, true},
+	}
+	for _, part := range ranges {
+		for _, n := range part.values {
+			if part.negative && n < 0 {
+				n = -n
+			}
+			if n < part.min || n > part.max {
+				return fmt.Errorf("%s value %d out of range", part.name, n)
+			}
+		}
+	}
+
+	for _, wd := range r.ByDay {
+		if wd.N < -53 || wd.N > 53 {
+			return fmt.Errorf("BYDAY ordinal %d out of range", wd.N)
+		}
+		if wd.N != 0 && r.Freq != "MONTHLY" && r.Freq != "YEARLY" {
+			return fmt.Errorf("BYDAY ordinals are only allowed with MONTHLY and YEARLY frequencies")
+		}
+		if wd.N != 0 && r.Freq == "YEARLY" && len(r.ByWeekNo) > 0 {
+			return fmt.Errorf("BYDAY ordinals cannot be used with BYWEEKNO")
+		}
+	}
+
+	if len(r.ByMonthDay) > 0 && r.Freq == "WEEKLY" {
+		return fmt.Errorf("BYMONTHDAY cannot be used with a WEEKLY frequency")
+	}
+	if len(r.ByYearDay) > 0 && (r.Freq == "DAILY" || r.Freq == "WEEKLY" || r.Freq == "MONTHLY") {
+		return fmt.Errorf("BYYEARDAY cannot be used with a %s frequency", r.Freq)
+	}
+	if len(r.ByWeekNo) > 0 && r.Freq != "YEARLY" {
+		return fmt.Errorf("BYWEEKNO can only be used with a YEARLY frequency")
+	}
+	if len(r.BySetPos) > 0 && !r.hasByParts() {
+		return fmt.Errorf("BYSETPOS must be used with another BYxxx part")
+	}
+
+	return nil
+}
+
+// hasByParts checks if the rule has any BYxxx part other than BYSETPOS.
+func (r RRule) hasByParts() bool {
+	return len(r.BySecond)+len(r.ByMinute)+len(r.ByHour)+len(r.ByDay)+len(r.ByMonthDay)+len(r.ByYearDay)+len(r.ByWeekNo)+len(r.ByMonth) > 0
+}
+
+// String serializes the rule with its parts in canonical order, leaving out the default INTERVAL and WKST.
+func (r RRule) String() string {
+	parts := make([]string, 0, len(recurrenceRuleParts))
+
+	for _, name := range recurrenceRuleParts {
+		var value string
+
+		switch name {
+		case "FREQ":
+			value = r.Freq
+		case "UNTIL":
+			if r.Until != nil {
+				layout := r.untilLayout
+				if layout == "" {
+					layout = untilLayoutUTC
+				}
+				until := *r.Until
+				if layout == untilLayoutUTC {
+					until = until.UTC()
+				}
+				value = until.Format(layout)
+			}
+		case "COUNT":
+			if r.Count > 0 {
+				value = strconv.Itoa(r.Count)
+			}
+		case "INTERVAL":
+			if r.Interval > 1 {
+				value = strconv.Itoa(r.Interval)
+			}
+		case "BYSECOND":
+			value = formatRuleInts(r.BySecond)
+		case "BYMINUTE":
+			value = formatRuleInts(r.ByMinute)
+		case "BYHOUR":
+			value = formatRuleInts(r.ByHour)
+		case "BYDAY":
+			days := make([]string, 0, len(r.ByDay))
+			for _, wd := range r.ByDay {
+				days = append(days, wd.String())
+			}
+			value = strings.Join(days, ",")
+		case "BYMONTHDAY":
+			value = formatRuleInts(r.ByMonthDay)
+		case "BYYEARDAY":
+			value = formatRuleInts(r.ByYearDay)
+		case "BYWEEKNO":
+			value = formatRuleInts(r.ByWeekNo)
+		case "BYMONTH":
+			value = formatRuleInts(r.ByMonth)
+		case "BYSETPOS":
+			value = formatRuleInts(r.BySetPos)
+		case "WKST":
+			if r.WeekStart != time.Monday {
+				value = icsDayNames[r.WeekStart]
+			}
+		}
+
+		if value != "" {
+			parts = append(parts, name+"="+value)
+		}
+	}
+
+	return strings.Join(parts, ";")
+}
+
+func (wd WeekdayNum) String() string {
+	if wd.N == 0 {
+		return icsDayNames[wd.Day]
+	}
+
+	return strconv.Itoa(wd.N) + icsDayNames[wd.Day]
+}
+
+// parseUntil reads UNTIL as a UTC date-time, or as a date or floating date-time kept as a wall-clock time.
+func (r *RRule) parseUntil(v string) error {
+	for _, layout := range []string{untilLayoutUTC, untilLayoutFloating, untilLayoutDate} {
+		if d, err := time.Parse(layout, v); err == nil {
+			r.Until, r.untilLayout = &d, layout
+			return nil
+		}
+	}
+
+	return fmt.Errorf("could not parse %s", v)
+}
+
+// ExpandRecurringEvent lists the occurrences of an event within the parsing range, from its RRULEs and RDATEs,
+// minus its EXDATEs and the occurrences of its EXRULEs. The instances refer to the event as their Master.
 func (gc *Gocal) ExpandRecurringEvent(buf *Event) []Event {
-	freq := buf.RecurrenceRule["FREQ"]
+	ev, truncated := gc.expand(buf, *gc.Start, *gc.End, gc.Recurrence.MaxInstances)
+	if truncated {
+		gc.warn(SeverityWarning, fmt.Errorf("recurring event truncated to %d instances", gc.Recurrence.MaxInstances))
+	}
+
+	return ev
+}
+
+// Occurrences lazily expands a recurring event, such as the ones returned with RecurrenceModeMasters, between two dates.
+// Its instances are reconciled with the overrides found in the feed.
+func (gc *Gocal) Occurrences(master *Event, from, to time.Time) []Event {
+	ev, _ := gc.expand(master, from, to, gc.Recurrence.MaxInstances)
+
+	return gc.reconcile(ev, []string{master.Uid}, from, to)
+}
 
-	until, err := parser.ParseTime(buf.RecurrenceRule["UNTIL"], map[string]string{}, parser.TimeEnd, false, gc.AllDayEventsTZ)
-	hasUntil := err == nil
+// expand lists the occurrences of an event within a range, up to limit (if not 0), and reports if some were left out.
+func (gc *Gocal) expand(buf *Event, from, to time.Time, limit int) ([]Event, bool) {
+	duration := buf.End.Sub(*buf.Start)
 
-	count, err := strconv.Atoi(buf.RecurrenceRule["COUNT"])
-	if err != nil {
-		count = -1
+	// All-day events last a number of days rather than an exact duration, which differ across DST changes
+	allDay := buf.RawStart.Params["VALUE"] == "DATE"
+	nominal := wallClock(*buf.End).Sub(wallClock(*buf.Start))
+
+	ends := make(map[int64]time.Time)
+	for _, rd := range buf.RecurrenceDates {
+		if rd.End != nil {
+			ends[rd.Start.UnixNano()] = *rd.End
+		}
 	}
 
-	interval, err := strconv.Atoi(buf.RecurrenceRule["INTERVAL"])
-	if err != nil {
-		interval = 1
+	it := buf.RecurrenceSet().Iterator()
+	it.bound(from.Add(-duration), to)
+
+	ev := make([]Event, 0)
+	for {
+		occurrence, ok := it.Next()
+		if !ok || occurrence.After(to) {
+			break
+		}
+
+		end := occurrence.Add(duration)
+		if allDay {
+			end = inLocation(wallClock(occurrence).Add(nominal), occurrence.Location())
+		}
+		if e, ok := ends[occurrence.UnixNano()]; ok {
+			end = e
+		}
+
+		occurrenceStart := occurrence
+
+		e := *buf
+		e.Start = &occurrence
+		e.End = &end
+		e.OccurrenceStart = &occurrenceStart
+		e.Master = buf
+
+		if !isInRange(e, from, to) {
+			continue
+		}
+		if limit > 0 && len(ev) == limit {
+			return ev, true
+		}
+
+		ev = append(ev, e)
+	}
+
+	return ev, false
+}
+
+// applyOverride derives an instance from a THISANDFUTURE override, moved by as much as the override moved its own instance.
+func applyOverride(o Event, instance Event) Event {
+	start := instance.Start.Add(o.Start.Sub(*o.RecurrenceID))
+	end := start.Add(o.End.Sub(*o.Start))
+	rid := *instance.Start
+
+	e := o
+	e.Start, e.End = &start, &end
+	e.RecurrenceID = &rid
+	e.RawRecurrenceID = RawDate{}
+	e.OccurrenceStart = instance.OccurrenceStart
+	e.Master = instance.Master
+
+	return e
+}
+
+// ruleIterator walks the periods of a rule from DTSTART, and yields its occurrences in chronological order.
+type ruleIterator struct {
+	rule      recurrence
+	loc       *time.Location
+	until     *time.Time
+	remaining int
+	years     int
+	months    int
+	days      int
+	step      time.Duration
+	period    time.Time
+	limit     *time.Time
+	pending   []time.Time
+	empty     int
+	done      bool
+}
+
+func newRuleIterator(rrule RRule, start time.Time) *ruleIterator {
+	it := &ruleIterator{rule: newRecurrence(rrule, start), loc: start.Location(), remaining: rrule.Count}
+
+	// UNTIL is an instant, dates and floating times being taken in the zone of DTSTART. A date includes the whole day.
+	if rrule.Until != nil {
+		until := *rrule.Until
+		switch rrule.untilLayout {
+		case untilLayoutDate:
+			until = inLocation(until.AddDate(0, 0, 1), it.loc).Add(-time.Nanosecond)
+		case untilLayoutFloating:
+			until = inLocation(until, it.loc)
+		}
+		it.until = &until
 	}
 
-	byMonth, err := strconv.Atoi(buf.RecurrenceRule["BYMONTH"])
-	hasByMonth := err == nil
+	if it.remaining < 1 {
+		it.remaining = -1
+	}
 
-	byDay, ok := buf.RecurrenceRule["BYDAY"]
-	hasByDay := ok
+	interval := rrule.Interval
+	if interval < 1 {
+		interval = 1
+	}
 
-	var years, days, months int
-	switch freq {
+	switch rrule.Freq {
+	case "SECONDLY":
+		it.step = time.Duration(interval) * time.Second
+	case "MINUTELY":
+		it.step = time.Duration(interval) * time.Minute
+	case "HOURLY":
+		it.step = time.Duration(interval) * time.Hour
 	case "DAILY":
-		days = interval
-		months = 0
-		years = 0
-		break
+		it.days = interval
 	case "WEEKLY":
-		days = 7 * interval
-		months = 0
-		years = 0
-		break
+		it.days = 7 * interval
 	case "MONTHLY":
-		days = 0
-		months = interval
-		years = 0
-		break
+		it.months = interval
 	case "YEARLY":
-		days = 0
-		months = 0
-		years = interval
-		break
+		it.years = interval
 	default:
-		return []Event{}
+		it.done = true
 	}
 
-	currentCount := 0
-	freqDateStart := buf.Start
-	freqDateEnd := buf.End
+	// Periods are walked on wall-clock times, kept as UTC values, occurrences are then placed in the zone of DTSTART
+	period := time.Date(start.Year(), start.Month(), start.Day(), start.Hour(), start.Minute(), start.Second(), 0, time.UTC)
+	switch rrule.Freq {
+	case "MINUTELY":
+		period = period.Truncate(time.Minute)
+	case "HOURLY":
+		period = period.Truncate(time.Hour)
+	case "DAILY":
+		period = period.Truncate(24 * time.Hour)
+	case "WEEKLY":
+		// Weeks start on WKST, which matters when only one week out of several is used
+		period = period.Truncate(24*time.Hour).AddDate(0, 0, -((int(period.Weekday()) - int(it.rule.WeekStart) + 7) % 7))
+	case "MONTHLY":
+		period = time.Date(period.Year(), period.Month(), 1, 0, 0, 0, 0, time.UTC)
+	case "YEARLY":
+		period = time.Date(period.Year(), time.January, 1, 0, 0, 0, 0, time.UTC)
+	}
+	it.period = period
 
-	ev := make([]Event, 0)
-	for {
-		weekDaysStart := freqDateStart
-		weekDaysEnd := freqDateEnd
-
-		if !hasByMonth || strings.Contains(fmt.Sprintf("%d", byMonth), weekDaysStart.Format("1")) {
-			if hasByDay {
-				for i := 0; i < 7 && count != 0; i++ {
-					excluded := false
-					for _, ex := range buf.ExcludeDates {
-						if ex.Equal(*weekDaysStart) {
-							excluded = true
-							break
-						}
-					}
+	return it
+}
 
-					if !excluded {
-						day := parseDayNameToIcsName(weekDaysStart.Format("Mon"))
-
-						if strings.Contains(byDay, day) {
-							currentCount++
-							count--
-
-							e := *buf
-							e.Start = weekDaysStart
-							e.End = weekDaysEnd
-							e.Uid = buf.Uid
-							e.Sequence = currentCount
-							if !hasUntil || (hasUntil && until.Format(YmdHis) >= weekDaysStart.Format(YmdHis)) {
-								if gc.IsInRange(e) {
-									ev = append(ev, e)
-								}
-							}
-
-						}
-					}
+// skipTo moves sub-daily rules close to the given time, as they can produce a lot of periods before it.
+// The occurrences of rules with COUNT are counted towards it, either by period when each one has a single
+// occurrence, or by day, days matching the rule and whose periods start at the same time having the same occurrences.
+func (it *ruleIterator) skipTo(t time.Time) {
+	if it.step == 0 || it.done {
+		return
+	}
 
-					newStart := weekDaysStart.AddDate(0, 0, 1)
-					newEnd := weekDaysEnd.AddDate(0, 0, 1)
-					weekDaysStart = &newStart
-					weekDaysEnd = &newEnd
-				}
-			} else {
-				excluded := false
-				for _, ex := range buf.ExcludeDates {
-					if ex.Equal(*weekDaysStart) {
-						excluded = true
-						break
-					}
-				}
+	skip := (t.Sub(inLocation(it.period, it.loc)) - 24*time.Hour) / it.step
+	if skip <= 0 {
+		return
+	}
+
+	switch {
+	case it.remaining < 0:
+		it.period = it.period.Add(skip * it.step)
+	case !it.rule.hasByParts():
+		if int64(skip) >= int64(it.remaining) {
+			it.remaining, it.done = 0, true
+			return
+		}
+		it.remaining -= int(skip)
+		it.period = it.period.Add(skip * it.step)
+	default:
+		target := wallClock(t.In(it.loc)).Truncate(24*time.Hour).AddDate(0, 0, -1)
+		first := wallClock(it.rule.start).Truncate(24 * time.Hour)
+		days := make(map[time.Duration]int)
+
+		for it.period.Before(target) && !it.done {
+			day := it.period.Truncate(24 * time.Hour)
+			next := day.AddDate(0, 0, 1)
 
-				currentCount++
-				count--
-
-				if !excluded {
-					e := *buf
-					e.Start = weekDaysStart
-					e.End = weekDaysEnd
-					e.Uid = buf.Uid
-					e.Sequence = currentCount
-					if !hasUntil || (hasUntil && until.Format(YmdHis) >= weekDaysStart.Format(YmdHis)) {
-						if gc.IsInRange(e) {
-							ev = append(ev, e)
-						}
+			if it.rule.matches(day) {
+				n, ok := days[it.period.Sub(day)]
+				if !ok {
+					for p := it.period; p.Before(next); p = p.Add(it.step) {
+						n += len(it.occurrences(p))
+					}
+					// The first day can have occurrences before DTSTART
+					if !day.Equal(first) {
+						days[it.period.Sub(day)] = n
 					}
 				}
+				it.count(n)
 			}
+
+			it.period = it.period.Add((next.Sub(it.period) + it.step - 1) / it.step * it.step)
 		}
+	}
+}
 
-		newStart := freqDateStart.AddDate(years, months, days)
-		newEnd := freqDateEnd.AddDate(years, months, days)
+// count counts skipped occurrences towards COUNT.
+func (it *ruleIterator) count(n int) {
+	if n >= it.remaining {
+		it.remaining, it.done = 0, true
+		return
+	}
+	it.remaining -= n
+}
 
-		freqDateStart = &newStart
-		freqDateEnd = &newEnd
+func (it *ruleIterator) peek() (time.Time, bool) {
+	for len(it.pending) == 0 {
+		if it.done {
+			return time.Time{}, false
+		}
+		it.fill()
+	}
+
+	return it.pending[0], true
+}
+
+func (it *ruleIterator) next() (time.Time, bool) {
+	o, ok := it.peek()
+	if ok {
+		it.pending = it.pending[1:]
+	}
 
-		if (count < 0 && weekDaysStart.After(*gc.End)) || count == 0 {
+	return o, ok
+}
+
+// fill expands the current period and moves to the next one.
+func (it *ruleIterator) fill() {
+	if it.remaining == 0 || it.empty > maxEmptyPeriods || it.period.Year() > maxRecurrenceYear || (it.limit != nil && inLocation(it.period, it.loc).After(*it.limit)) {
+		it.done = true
+		return
+	}
+
+	// Sub-daily periods are skipped by whole days, hours or minutes when those cannot match
+	if it.step > 0 {
+		if target, skip := it.rule.nextCandidate(it.period); skip {
+			it.empty++
+			it.period = it.period.Add((target.Sub(it.period) + it.step - 1) / it.step * it.step)
+			return
+		}
+	}
+
+	occurrences := it.occurrences(it.period)
+	if len(occurrences) == 0 {
+		it.empty++
+	} else {
+		it.empty = 0
+	}
+
+	for _, occurrence := range occurrences {
+		if it.until != nil && occurrence.After(*it.until) {
+			it.done = true
 			break
 		}
-		if hasUntil && until.Format(YmdHis) <= freqDateStart.Format(YmdHis) {
+		if it.remaining == 0 {
+			it.done = true
 			break
 		}
+		if it.remaining > 0 {
+			it.remaining--
+		}
+
+		it.pending = append(it.pending, occurrence)
 	}
 
-	return ev
+	it.period = it.period.AddDate(it.years, it.months, it.days).Add(it.step)
+}
+
+// occurrences lists the occurrences of a period, regardless of UNTIL and COUNT.
+func (it *ruleIterator) occurrences(period time.Time) []time.Time {
+	candidates := make([]time.Time, 0)
+	for _, o := range it.rule.expandPeriod(period) {
+		candidates = append(candidates, inLocation(o.Add(time.Duration(it.rule.start.Nanosecond())), it.loc))
+	}
+
+	occurrences := make([]time.Time, 0)
+	for _, o := range applySetPos(candidates, it.rule.BySetPos) {
+		if !o.Before(it.rule.start) {
+			occurrences = append(occurrences, o)
+		}
+	}
+
+	return occurrences
+}
+
+// inLocation places a wall-clock time in a zone. As specified by RFC 5545, a time occurring twice when clocks
+// are set back is the first one, and a time skipped when clocks are set forward uses the offset from before the gap.
+func inLocation(wall time.Time, loc *time.Location) time.Time {
+	t := time.Date(wall.Year(), wall.Month(), wall.Day(), wall.Hour(), wall.Minute(), wall.Second(), wall.Nanosecond(), loc)
+
+	_, offset := t.Add(-24 * time.Hour).Zone()
+	earlier := wallClock(wall).Add(-time.Duration(offset) * time.Second).In(loc)
+
+	if !sameWallClock(t, wall) || (sameWallClock(earlier, wall) && earlier.Before(t)) {
+		return earlier
+	}
+
+	return t
+}
+
+func sameWallClock(a, b time.Time) bool {
+	ay, am, ad := a.Date()
+	by, bm, bd := b.Date()
+
+	return ay == by && am == bm && ad == bd && a.Hour() == b.Hour() && a.Minute() == b.Minute() && a.Second() == b.Second()
+}
+
+// newRecurrence applies the defaults of a rule. When none of its BYxxx parts selects days,
+// they default to the corresponding parts of DTSTART, as specified by RFC 5545.
+func newRecurrence(rule RRule, start time.Time) recurrence {
+	r := recurrence{RRule: rule, start: start}
+
+	// Leap seconds cannot be represented, second 60 is taken as the last second of the minute
+	if containsInt(r.BySecond, 60) {
+		r.BySecond = make([]int, 0, len(rule.BySecond))
+		for _, s := range rule.BySecond {
+			if s == 60 {
+				s = 59
+			}
+			if !containsInt(r.BySecond, s) {
+				r.BySecond = append(r.BySecond, s)
+			}
+		}
+	}
+
+	if len(r.ByWeekNo) == 0 && len(r.ByYearDay) == 0 && len(r.ByMonthDay) == 0 && len(r.ByDay) == 0 {
+		switch r.Freq {
+		case "YEARLY":
+			if len(r.ByMonth) == 0 {
+				r.ByMonth = []int{int(start.Month())}
+			}
+			r.ByMonthDay = []int{start.Day()}
+		case "MONTHLY":
+			r.ByMonthDay = []int{start.Day()}
+		case "WEEKLY":
+			r.ByDay = []WeekdayNum{{Day: start.Weekday()}}
+		}
+	}
+
+	return r
+}
+
+// expandPeriod lists the wall-clock times of a recurrence period (from a second to a year, starting at the given time)
+// at which the rule produces occurrences, in chronological order.
+func (r recurrence) expandPeriod(period time.Time) []time.Time {
+	// Parts of a finer resolution than the frequency expand the period, other ones limit it
+	hours := r.timeValues(r.ByHour, period.Hour(), r.start.Hour(), "HOURLY", "MINUTELY", "SECONDLY")
+	minutes := r.timeValues(r.ByMinute, period.Minute(), r.start.Minute(), "MINUTELY", "SECONDLY")
+	seconds := r.timeValues(r.BySecond, period.Second(), r.start.Second(), "SECONDLY")
+
+	occurrences := make([]time.Time, 0)
+	for _, day := range r.expandDays(period) {
+		for _, h := range hours {
+			for _, m := range minutes {
+				for _, s := range seconds {
+					occurrences = append(occurrences, time.Date(day.Year(), day.Month(), day.Day(), h, m, s, 0, time.UTC))
+				}
+			}
+		}
+	}
+
+	return occurrences
+}
+
+// nextCandidate checks whether a sub-daily period is on a day, hour or minute that the rule cannot match,
+// and returns the start of the next one in that case.
+func (r recurrence) nextCandidate(period time.Time) (time.Time, bool) {
+	day := period.Truncate(24 * time.Hour)
+	if !r.matches(day) {
+		return day.AddDate(0, 0, 1), true
+	}
+
+	if r.Freq != "HOURLY" && len(r.ByHour) > 0 && !containsInt(r.ByHour, period.Hour()) {
+		return period.Truncate(time.Hour).Add(time.Hour), true
+	}
+
+	if r.Freq == "SECONDLY" && len(r.ByMinute) > 0 && !containsInt(r.ByMinute, period.Minute()) {
+		return period.Truncate(time.Minute).Add(time.Minute), true
+	}
+
+	return period, false
+}
+
+// timeValues returns the values of a time part (hour, minute or second) for a period. For the frequencies
+// at which the part is given by the period itself, the rule values only limit it. Otherwise, they list
+// the values to use, defaulting to the one of DTSTART.
+func (r recurrence) timeValues(values []int, current, initial int, limiting ...string) []int {
+	for _, freq := range limiting {
+		if r.Freq == freq {
+			if len(values) > 0 && !containsInt(values, current) {
+				return nil
+			}
+			return []int{current}
+		}
+	}
+
+	if len(values) == 0 {
+		return []int{initial}
+	}
+
+	sorted := append([]int{}, values...)
+	sort.Ints(sorted)
+
+	return sorted
+}
+
+// expandDays lists the days of a period on which the rule produces occurrences.
+func (r recurrence) expandDays(period time.Time) []time.Time {
+	period = period.Truncate(24 * time.Hour)
+
+	var end time.Time
+	switch r.Freq {
+	case "SECONDLY", "MINUTELY", "HOURLY", "DAILY":
+		end = period.AddDate(0, 0, 1)
+	case "WEEKLY":
+		end = period.AddDate(0, 0, 7)
+	case "MONTHLY":
+		end = period.AddDate(0, 1, 0)
+	case "YEARLY":
+		end = period.AddDate(1, 0, 0)
+	}
+
+	days := make([]time.Time, 0)
+	for d := period; d.Before(end); d = d.AddDate(0, 0, 1) {
+		if r.matches(d) {
+			days = append(days, d)
+		}
+	}
+
+	return days
+}
+
+// matches checks a day against every BYxxx part of the rule.
+func (r recurrence) matches(d time.Time) bool {
+	if len(r.ByMonth) > 0 && !containsInt(r.ByMonth, int(d.Month())) {
+		return false
+	}
+
+	if len(r.ByWeekNo) > 0 {
+		week, weeks := weekNumber(d, r.WeekStart)
+		if !matchesIndex(r.ByWeekNo, week, weeks) {
+			return false
+		}
+	}
+
+	if len(r.ByYearDay) > 0 && !matchesIndex(r.ByYearDay, d.YearDay(), daysIn(d.Year())) {
+		return false
+	}
+
+	if len(r.ByMonthDay) > 0 && !matchesIndex(r.ByMonthDay, d.Day(), daysInMonth(d)) {
+		return false
+	}
+
+	if len(r.ByDay) > 0 && !r.matchesByDay(d) {
+		return false
+	}
+
+	return true
+}
+
+// matchesByDay checks a day against BYDAY. Ordinals are counted within the month for monthly rules
+// (and yearly rules with BYMONTH), and within the year for yearly rules. They are ignored otherwise.
+func (r recurrence) matchesByDay(d time.Time) bool {
+	for _, wd := range r.ByDay {
+		if wd.Day != d.Weekday() {
+			continue
+		}
+		if wd.N == 0 {
+			return true
+		}
+
+		var nth, last int
+		switch {
+		case r.Freq == "MONTHLY" || (r.Freq == "YEARLY" && len(r.ByMonth) > 0):
+			nth, last = (d.Day()-1)/7+1, (daysInMonth(d)-d.Day())/7+1
+		case r.Freq == "YEARLY":
+			nth, last = (d.YearDay()-1)/7+1, (daysIn(d.Year())-d.YearDay())/7+1
+		default:
+			return true
+		}
+
+		if wd.N == nth || wd.N == -last {
+			return true
+		}
+	}
+
+	return false
+}
+
+// applySetPos keeps the occurrences of a period at the positions given by BYSETPOS, negative positions counting from the end.
+func applySetPos(occurrences []time.Time, bySetPos []int) []time.Time {
+	if len(bySetPos) == 0 {
+		return occurrences
+	}
+
+	selected := make([]time.Time, 0)
+	for i, o := range occurrences {
+		if matchesIndex(bySetPos, i+1, len(occurrences)) {
+			selected = append(selected, o)
+		}
+	}
+
+	return selected
+}
+
+// matchesIndex checks if the 1-based position n, among total values, is listed, negative values counting from the end.
+func matchesIndex(values []int, n, total int) bool {
+	for _, v := range values {
+		if v == n || (v < 0 && total+v+1 == n) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsInt(values []int, n int) bool {
+	for _, v := range values {
+		if v == n {
+			return true
+		}
+	}
+	return false
+}
+
+// weekNumber returns the week number of a day and the number of weeks in its week-numbering year.
+// Week 1 is the first week, starting on weekStart, with at least four days in the calendar year.
+func weekNumber(d time.Time, weekStart time.Weekday) (int, int) {
+	year := d.Year()
+	first := firstWeek(year, weekStart)
+
+	if d.Before(first) {
+		year--
+		first = firstWeek(year, weekStart)
+	} else if next := firstWeek(year+1, weekStart); !d.Before(next) {
+		year++
+		first = next
+	}
+
+	weeks := daysBetween(first, firstWeek(year+1, weekStart)) / 7
+
+	return daysBetween(first, d)/7 + 1, weeks
+}
+
+// firstWeek returns the first day of week 1 of a year.
+func firstWeek(year int, weekStart time.Weekday) time.Time {
+	jan1 := time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC)
+	offset := (int(jan1.Weekday()) - int(weekStart) + 7) % 7
+
+	if 7-offset >= 4 {
+		return jan1.AddDate(0, 0, -offset)
+	}
+
+	return jan1.AddDate(0, 0, 7-offset)
+}
+
+func daysBetween(from, to time.Time) int {
+	return int(to.Sub(from).Hours() / 24)
+}
+
+// wallClock returns the wall-clock time of a time, as a UTC value.
+func wallClock(d time.Time) time.Time {
+	return time.Date(d.Year(), d.Month(), d.Day(), d.Hour(), d.Minute(), d.Second(), d.Nanosecond(), time.UTC)
+}
+
+func daysIn(year int) int {
+	return time.Date(year, time.December, 31, 0, 0, 0, 0, time.UTC).YearDay()
+}
+
+func daysInMonth(d time.Time) int {
+	return time.Date(d.Year(), d.Month()+1, 0, 0, 0, 0, 0, time.UTC).Day()
+}
+
+// parseByDay parses a BYDAY rule part, such as MO,TU or 2TU,-1FR.
+func parseByDay(v string) ([]WeekdayNum, error) {
+	values := make([]WeekdayNum, 0)
+
+	for _, spec := range strings.Split(v, ",") {
+		if len(spec) < 2 {
+			return nil, fmt.Errorf("unknown day %s", spec)
+		}
+
+		day, ok := parseIcsDayName(spec[len(spec)-2:])
+		if !ok {
+			return nil, fmt.Errorf("unknown day %s", spec)
+		}
+
+		n := 0
+		if len(spec) > 2 {
+			var err error
+			if n, err = strconv.Atoi(spec[:len(spec)-2]); err != nil || n == 0 {
+				return nil, fmt.Errorf("invalid ordinal in %s", spec)
+			}
+		}
+
+		values = append(values, WeekdayNum{N: n, Day: day})
+	}
+
+	return values, nil
+}
+
+// parseRuleInts parses a list of integers, such as a BYMONTH or BYSETPOS rule part.
+func parseRuleInts(v string) ([]int, error) {
+	values := make([]int, 0)
+
+	for _, s := range strings.Split(v, ",") {
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, n)
+	}
+
+	return values, nil
+}
+
+func formatRuleInts(values []int) string {
+	s := make([]string, 0, len(values))
+	for _, n := range values {
+		s = append(s, strconv.Itoa(n))
+	}
+
+	return strings.Join(s, ",")
 }
 
-func parseDayNameToIcsName(day string) string {
+func parseIcsDayName(day string) (time.Weekday, bool) {
 	switch day {
-	case "Mon":
-		return "MO"
-	case "Tue":
-		return "TU"
-	case "Wed":
-		return "WE"
-	case "Thu":
-		return "TH"
-	case "Fri":
-		return "FR"
-	case "Sat":
-		return "SA"
-	case "Sun":
-		return "SU"
+	case "MO":
+		return time.Monday, true
+	case "TU":
+		return time.Tuesday, true
+	case "WE":
+		return time.Wednesday, true
+	case "TH":
+		return time.Thursday, true
+	case "FR":
+		return time.Friday, true
+	case "SA":
+		return time.Saturday, true
+	case "SU":
+		return time.Sunday, true
 	default:
-		return ""
+		return time.Sunday, false
 	}
 }
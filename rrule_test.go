@@ -0,0 +1,516 @@
+package gocal
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func expandRule(t *testing.T, dtstart, rule string, rangeStart, rangeEnd time.Time) []string {
+	gc := NewParser(nil)
+	gc.Start, gc.End = &rangeStart, &rangeEnd
+
+	start, err := time.Parse("20060102T150405Z", dtstart)
+	assert.Nil(t, err)
+	end := start.Add(time.Hour)
+
+	rrule, err := ParseRRule(rule)
+	assert.Nil(t, err)
+
+	dates := make([]string, 0)
+	for _, e := range gc.ExpandRecurringEvent(&Event{Uid: "rule@gocal", Start: &start, End: &end, RecurrenceRule: []RRule{*rrule}}) {
+		dates = append(dates, e.Start.Format("2006-01-02"))
+	}
+
+	return dates
+}
+
+func Test_ExpandOrdinalByDay(t *testing.T) {
+	from, to := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2019, 12, 31, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		dtstart  string
+		rule     string
+		expected []string
+	}{
+		{"second tuesday", "20190108T090000Z", "FREQ=MONTHLY;BYDAY=2TU;COUNT=4", []string{"2019-01-08", "2019-02-12", "2019-03-12", "2019-04-09"}},
+		{"last friday", "20190125T090000Z", "FREQ=MONTHLY;BYDAY=-1FR;COUNT=4", []string{"2019-01-25", "2019-02-22", "2019-03-29", "2019-04-26"}},
+		{"first and last monday", "20190107T090000Z", "FREQ=MONTHLY;BYDAY=1MO,-1MO;COUNT=4", []string{"2019-01-07", "2019-01-28", "2019-02-04", "2019-02-25"}},
+		{"every other month", "20190101T090000Z", "FREQ=MONTHLY;INTERVAL=2;BYDAY=1WE;COUNT=3", []string{"2019-01-02", "2019-03-06", "2019-05-01"}},
+		{"fifth monday", "20190101T090000Z", "FREQ=MONTHLY;BYDAY=5MO;COUNT=2", []string{"2019-04-29", "2019-07-29"}},
+		{"plain weekdays", "20190101T090000Z", "FREQ=MONTHLY;BYDAY=SA;COUNT=5", []string{"2019-01-05", "2019-01-12", "2019-01-19", "2019-01-26", "2019-02-02"}},
+		{"twentieth monday of the year", "20190101T090000Z", "FREQ=YEARLY;BYDAY=20MO", []string{"2019-05-20"}},
+		{"last sunday of the year", "20190101T090000Z", "FREQ=YEARLY;BYDAY=-1SU", []string{"2019-12-29"}},
+		{"first sunday of november", "20190101T090000Z", "FREQ=YEARLY;BYMONTH=11;BYDAY=1SU", []string{"2019-11-03"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, expandRule(t, test.dtstart, test.rule, from, to))
+		})
+	}
+}
+
+func Test_ParseByDay(t *testing.T) {
+	days, err := parseByDay("MO,2TU,-1FR,+53SU")
+	assert.Nil(t, err)
+	assert.Equal(t, []WeekdayNum{{0, time.Monday}, {2, time.Tuesday}, {-1, time.Friday}, {53, time.Sunday}}, days)
+
+	for _, v := range []string{"XX", "0MO", "aTU", "WE,", ""} {
+		_, err := parseByDay(v)
+		assert.NotNil(t, err, v)
+	}
+}
+
+func Test_ExpandByRules(t *testing.T) {
+	from, to := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		dtstart  string
+		rule     string
+		expected []string
+	}{
+		{"month days", "20190101T090000Z", "FREQ=MONTHLY;BYMONTHDAY=15,-1;COUNT=5", []string{"2019-01-15", "2019-01-31", "2019-02-15", "2019-02-28", "2019-03-15"}},
+		{"skipped month days", "20190131T090000Z", "FREQ=MONTHLY;COUNT=3", []string{"2019-01-31", "2019-03-31", "2019-05-31"}},
+		{"limiting month days", "20190101T090000Z", "FREQ=DAILY;BYMONTHDAY=1;COUNT=3", []string{"2019-01-01", "2019-02-01", "2019-03-01"}},
+		{"last weekday of the month", "20190101T090000Z", "FREQ=MONTHLY;BYDAY=MO,TU,WE,TH,FR;BYSETPOS=-1;COUNT=3", []string{"2019-01-31", "2019-02-28", "2019-03-29"}},
+		{"second and last weekend day", "20190101T090000Z", "FREQ=MONTHLY;BYDAY=SA,SU;BYSETPOS=2,-1;COUNT=4", []string{"2019-01-06", "2019-01-27", "2019-02-03", "2019-02-24"}},
+		{"year days", "20190101T090000Z", "FREQ=YEARLY;BYYEARDAY=1,100,-1;COUNT=5", []string{"2019-01-01", "2019-04-10", "2019-12-31", "2020-01-01", "2020-04-09"}},
+		{"week numbers", "20190101T090000Z", "FREQ=YEARLY;BYWEEKNO=20;BYDAY=MO", []string{"2019-05-13", "2020-05-11"}},
+		{"last week", "20190101T090000Z", "FREQ=YEARLY;BYWEEKNO=-1;BYDAY=TH", []string{"2019-12-26", "2020-12-31"}},
+		{"friday the 13th", "20190101T090000Z", "FREQ=MONTHLY;BYDAY=FR;BYMONTHDAY=13;COUNT=3", []string{"2019-09-13", "2019-12-13", "2020-03-13"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, expandRule(t, test.dtstart, test.rule, from, to))
+		})
+	}
+}
+
+func Test_WeekNumber(t *testing.T) {
+	week, weeks := weekNumber(time.Date(2019, 12, 30, 0, 0, 0, 0, time.UTC), time.Monday)
+	assert.Equal(t, 1, week)
+	assert.Equal(t, 53, weeks)
+
+	week, weeks = weekNumber(time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC), time.Monday)
+	assert.Equal(t, 53, week)
+	assert.Equal(t, 53, weeks)
+
+	week, _ = weekNumber(time.Date(2019, 1, 6, 0, 0, 0, 0, time.UTC), time.Sunday)
+	assert.Equal(t, 2, week)
+}
+
+func Test_ExpandSubDaily(t *testing.T) {
+	from, to := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2019, 1, 3, 0, 0, 0, 0, time.UTC)
+
+	format := func(t *testing.T, dtstart, rule string) []string {
+		gc := NewParser(nil)
+		gc.Start, gc.End = &from, &to
+
+		start, _ := time.Parse("20060102T150405Z", dtstart)
+		end := start.Add(time.Second)
+		rrule, err := ParseRRule(rule)
+		assert.Nil(t, err)
+
+		dates := make([]string, 0)
+		for _, e := range gc.ExpandRecurringEvent(&Event{Uid: "rule@gocal", Start: &start, End: &end, RecurrenceRule: []RRule{*rrule}}) {
+			dates = append(dates, e.Start.Format("2006-01-02 15:04:05"))
+		}
+		return dates
+	}
+
+	tests := []struct {
+		name     string
+		dtstart  string
+		rule     string
+		expected []string
+	}{
+		{"every eight hours", "20190101T060000Z", "FREQ=HOURLY;INTERVAL=8", []string{"2019-01-01 06:00:00", "2019-01-01 14:00:00", "2019-01-01 22:00:00", "2019-01-02 06:00:00", "2019-01-02 14:00:00", "2019-01-02 22:00:00"}},
+		{"hourly limited by hour", "20190101T083000Z", "FREQ=HOURLY;BYHOUR=9,10;BYDAY=WE", []string{"2019-01-02 09:30:00", "2019-01-02 10:30:00"}},
+		{"hourly expanded by minute", "20190101T090000Z", "FREQ=HOURLY;BYMINUTE=0,30;COUNT=3", []string{"2019-01-01 09:00:00", "2019-01-01 09:30:00", "2019-01-01 10:00:00"}},
+		{"every twenty minutes", "20190101T090000Z", "FREQ=MINUTELY;INTERVAL=20;BYHOUR=9,10;COUNT=7", []string{"2019-01-01 09:00:00", "2019-01-01 09:20:00", "2019-01-01 09:40:00", "2019-01-01 10:00:00", "2019-01-01 10:20:00", "2019-01-01 10:40:00", "2019-01-02 09:00:00"}},
+		{"every fifteen seconds", "20190101T090000Z", "FREQ=SECONDLY;INTERVAL=15;COUNT=3", []string{"2019-01-01 09:00:00", "2019-01-01 09:00:15", "2019-01-01 09:00:30"}},
+		{"daily shifts", "20190101T060000Z", "FREQ=DAILY;BYHOUR=6,14,22;BYMINUTE=0;COUNT=4", []string{"2019-01-01 06:00:00", "2019-01-01 14:00:00", "2019-01-01 22:00:00", "2019-01-02 06:00:00"}},
+		{"started long ago", "20100101T000000Z", "FREQ=MINUTELY;INTERVAL=720", []string{"2019-01-01 12:00:00", "2019-01-02 00:00:00", "2019-01-02 12:00:00"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, format(t, test.dtstart, test.rule))
+		})
+	}
+}
+
+func Test_SkipCountedSubDaily(t *testing.T) {
+	paris, _ := time.LoadLocation("Europe/Paris")
+	start := time.Date(2018, 12, 30, 8, 20, 10, 0, paris)
+	from, to := time.Date(2019, 3, 30, 12, 0, 0, 0, paris), time.Date(2019, 4, 1, 0, 0, 0, 0, paris)
+
+	rules := []string{
+		"FREQ=HOURLY;INTERVAL=3;COUNT=1000",
+		"FREQ=HOURLY;BYHOUR=2,3,9;BYDAY=SA,SU;COUNT=100",
+		"FREQ=MINUTELY;INTERVAL=7;COUNT=30000",
+		"FREQ=MINUTELY;INTERVAL=30;BYHOUR=1,2,3;BYMINUTE=20,50;COUNT=700",
+		"FREQ=SECONDLY;INTERVAL=20;BYMINUTE=0;BYHOUR=2,9;COUNT=20000",
+		"FREQ=SECONDLY;INTERVAL=7;BYHOUR=9;BYMINUTE=0,1;COUNT=2000",
+		"FREQ=HOURLY;INTERVAL=25;BYDAY=SA,SU;COUNT=1000",
+	}
+
+	// Occurrences found after skipping to the range are the ones found by walking every period from DTSTART
+	for _, rule := range rules {
+		t.Run(rule, func(t *testing.T) {
+			rrule, err := ParseRRule(rule)
+			assert.Nil(t, err)
+
+			set := &RecurrenceSet{Start: start, Rules: []RRule{*rrule}}
+
+			expected := make([]time.Time, 0)
+			for _, o := range set.All(0) {
+				if !o.Before(from) && !o.After(to) {
+					expected = append(expected, o)
+				}
+			}
+
+			assert.NotEmpty(t, expected)
+			assert.Equal(t, expected, set.Between(from, to))
+		})
+	}
+}
+
+func Test_ExpandByMonth(t *testing.T) {
+	from, to := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2020, 12, 31, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		dtstart  string
+		rule     string
+		expected []string
+	}{
+		{"school terms", "20190101T090000Z", "FREQ=YEARLY;BYMONTH=3,6,9,12;BYDAY=1MO;COUNT=5", []string{"2019-03-04", "2019-06-03", "2019-09-02", "2019-12-02", "2020-03-02"}},
+		{"yearly expansion", "20190115T090000Z", "FREQ=YEARLY;BYMONTH=1,7;COUNT=4", []string{"2019-01-15", "2019-07-15", "2020-01-15", "2020-07-15"}},
+		{"november only", "20190101T090000Z", "FREQ=MONTHLY;BYMONTH=11;COUNT=2", []string{"2019-11-01", "2020-11-01"}},
+		{"limiting days", "20190101T090000Z", "FREQ=DAILY;BYMONTH=2,12;BYMONTHDAY=1,2;COUNT=5", []string{"2019-02-01", "2019-02-02", "2019-12-01", "2019-12-02", "2020-02-01"}},
+		{"limiting weeks", "20190101T090000Z", "FREQ=WEEKLY;BYMONTH=1,2;BYDAY=TU;UNTIL=20190301T000000Z", []string{"2019-01-01", "2019-01-08", "2019-01-15", "2019-01-22", "2019-01-29", "2019-02-05", "2019-02-12", "2019-02-19", "2019-02-26"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, expandRule(t, test.dtstart, test.rule, from, to))
+		})
+	}
+}
+
+func Test_ExpandWeekStart(t *testing.T) {
+	from, to := time.Date(1997, 8, 1, 0, 0, 0, 0, time.UTC), time.Date(1997, 12, 31, 0, 0, 0, 0, time.UTC)
+
+	// Examples from RFC 5545, section 3.8.5.3
+	assert.Equal(t, []string{"1997-08-05", "1997-08-10", "1997-08-19", "1997-08-24"}, expandRule(t, "19970805T090000Z", "FREQ=WEEKLY;INTERVAL=2;COUNT=4;BYDAY=TU,SU;WKST=MO", from, to))
+	assert.Equal(t, []string{"1997-08-05", "1997-08-17", "1997-08-19", "1997-08-31"}, expandRule(t, "19970805T090000Z", "FREQ=WEEKLY;INTERVAL=2;COUNT=4;BYDAY=TU,SU;WKST=SU", from, to))
+
+	// Without WKST, weeks start on monday
+	assert.Equal(t, []string{"1997-08-05", "1997-08-10", "1997-08-19", "1997-08-24"}, expandRule(t, "19970805T090000Z", "FREQ=WEEKLY;INTERVAL=2;COUNT=4;BYDAY=TU,SU", from, to))
+	assert.Equal(t, []string{"1997-08-05", "1997-08-19", "1997-09-02"}, expandRule(t, "19970805T090000Z", "FREQ=WEEKLY;INTERVAL=2;COUNT=3;WKST=SU", from, to))
+}
+
+const recurrenceDatesICS = `BEGIN:VCALENDAR
+BEGIN:VEVENT
+UID:rdate@gocal
+DTSTAMP:20190101T000000Z
+DTSTART:20190107T090000Z
+DTEND:20190107T100000Z
+SUMMARY:Weekly, with extra dates
+RRULE:FREQ=WEEKLY;COUNT=3
+RDATE:20190108T090000Z,20190114T090000Z
+RDATE;VALUE=PERIOD:20190110T140000Z/20190110T180000Z,20190111T140000Z/PT30M
+RDATE;VALUE=DATE:20190125
+RDATE:20190130T090000Z
+EXDATE:20190108T090000Z
+END:VEVENT
+BEGIN:VEVENT
+UID:rdate@gocal
+DTSTAMP:20190101T000000Z
+RECURRENCE-ID:20190130T090000Z
+DTSTART:20190131T090000Z
+DTEND:20190131T100000Z
+SUMMARY:Moved extra date
+END:VEVENT
+BEGIN:VEVENT
+UID:rdate-only@gocal
+DTSTAMP:20190101T000000Z
+DTSTART:20190102T090000Z
+DTEND:20190102T100000Z
+SUMMARY:Extra dates without rule
+RDATE:20190103T090000Z
+END:VEVENT
+END:VCALENDAR`
+
+func Test_RecurrenceDates(t *testing.T) {
+	start, end := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2019, 2, 28, 0, 0, 0, 0, time.UTC)
+
+	gc := NewParser(strings.NewReader(recurrenceDatesICS))
+	gc.Start, gc.End = &start, &end
+	err := gc.Parse()
+
+	assert.Nil(t, err)
+
+	dates := make(map[string][]string)
+	for _, e := range gc.Events {
+		dates[e.Uid] = append(dates[e.Uid], e.Start.Format("2006-01-02 15:04")+" - "+e.End.Format("2006-01-02 15:04"))
+	}
+
+	assert.Equal(t, []string{
+		"2019-01-07 09:00 - 2019-01-07 10:00",
+		"2019-01-10 14:00 - 2019-01-10 18:00",
+		"2019-01-11 14:00 - 2019-01-11 14:30",
+		"2019-01-14 09:00 - 2019-01-14 10:00",
+		"2019-01-21 09:00 - 2019-01-21 10:00",
+		"2019-01-25 00:00 - 2019-01-25 01:00",
+		"2019-01-31 09:00 - 2019-01-31 10:00",
+	}, dates["rdate@gocal"])
+
+	assert.Equal(t, []string{
+		"2019-01-02 09:00 - 2019-01-02 10:00",
+		"2019-01-03 09:00 - 2019-01-03 10:00",
+	}, dates["rdate-only@gocal"])
+}
+
+const multipleRulesICS = `BEGIN:VCALENDAR
+BEGIN:VEVENT
+UID:rules@gocal
+DTSTAMP:20190101T000000Z
+DTSTART:20190701T090000Z
+DTEND:20190701T100000Z
+SUMMARY:Every monday and the 1st of the month, except in August
+RRULE:FREQ=WEEKLY;BYDAY=MO
+RRULE:FREQ=MONTHLY;BYMONTHDAY=1
+EXRULE:FREQ=DAILY;BYMONTH=8
+END:VEVENT
+END:VCALENDAR`
+
+func Test_MultipleRules(t *testing.T) {
+	start, end := time.Date(2019, 7, 1, 0, 0, 0, 0, time.UTC), time.Date(2019, 9, 10, 0, 0, 0, 0, time.UTC)
+
+	gc := NewParser(strings.NewReader(multipleRulesICS))
+	gc.Start, gc.End = &start, &end
+	err := gc.Parse()
+
+	assert.Nil(t, err)
+
+	dates := make([]string, 0)
+	for _, e := range gc.Events {
+		dates = append(dates, e.Start.Format("2006-01-02"))
+	}
+
+	assert.Equal(t, []string{"2019-07-01", "2019-07-08", "2019-07-15", "2019-07-22", "2019-07-29", "2019-09-01", "2019-09-02", "2019-09-09"}, dates)
+	assert.Len(t, gc.Events[0].RecurrenceRule, 2)
+	assert.Len(t, gc.Events[0].ExcludeRules, 1)
+}
+
+func Test_ParseRRule(t *testing.T) {
+	rule, err := ParseRRule("FREQ=MONTHLY;INTERVAL=2;BYDAY=2TU,-1FR;BYMONTH=1,6;WKST=SU;UNTIL=20191231T235959Z")
+
+	assert.Nil(t, err)
+	assert.Equal(t, "MONTHLY", rule.Freq)
+	assert.Equal(t, 2, rule.Interval)
+	assert.Equal(t, 0, rule.Count)
+	assert.Equal(t, time.Date(2019, 12, 31, 23, 59, 59, 0, time.UTC), *rule.Until)
+	assert.Equal(t, time.Sunday, rule.WeekStart)
+	assert.Equal(t, []WeekdayNum{{2, time.Tuesday}, {-1, time.Friday}}, rule.ByDay)
+	assert.Equal(t, []int{1, 6}, rule.ByMonth)
+
+	rule, err = ParseRRule("FREQ=DAILY;COUNT=3;X-NAME=value;")
+
+	assert.Nil(t, err)
+	assert.Equal(t, 1, rule.Interval)
+	assert.Equal(t, 3, rule.Count)
+	assert.Equal(t, time.Monday, rule.WeekStart)
+}
+
+func Test_InvalidRRule(t *testing.T) {
+	tests := []string{
+		"",
+		"COUNT=3",
+		"FREQ=FORTNIGHTLY",
+		"FREQ=DAILY;COUNT=abc",
+		"FREQ=DAILY;COUNT=0",
+		"FREQ=DAILY;INTERVAL=-1",
+		"FREQ=DAILY;COUNT=3;UNTIL=20190101",
+		"FREQ=DAILY;UNTIL=tomorrow",
+		"FREQ=DAILY;FREQ=WEEKLY",
+		"FREQ=DAILY;BYWHATEVER=1",
+		"FREQ=DAILY;BYHOUR=24",
+		"FREQ=DAILY;BYMINUTE=60",
+		"FREQ=DAILY;BYSECOND=61",
+		"FREQ=YEARLY;BYMONTH=13",
+		"FREQ=MONTHLY;BYMONTHDAY=0",
+		"FREQ=MONTHLY;BYMONTHDAY=-32",
+		"FREQ=WEEKLY;BYMONTHDAY=1",
+		"FREQ=MONTHLY;BYYEARDAY=100",
+		"FREQ=MONTHLY;BYWEEKNO=1",
+		"FREQ=YEARLY;BYWEEKNO=54",
+		"FREQ=WEEKLY;BYDAY=2TU",
+		"FREQ=YEARLY;BYWEEKNO=1;BYDAY=1MO",
+		"FREQ=MONTHLY;BYDAY=XX",
+		"FREQ=MONTHLY;BYSETPOS=1",
+		"FREQ=MONTHLY;BYDAY=MO;WKST=XX",
+	}
+
+	for _, test := range tests {
+		_, err := ParseRRule(test)
+
+		assert.IsType(t, RRuleError{}, err, test)
+	}
+
+	assert.NotNil(t, RRule{Freq: "DAILY", Count: 2, Until: &time.Time{}}.Validate())
+	assert.Nil(t, RRule{Freq: "DAILY"}.Validate())
+}
+
+func Test_RRuleString(t *testing.T) {
+	tests := []struct {
+		rule     string
+		expected string
+	}{
+		{"FREQ=DAILY", "FREQ=DAILY"},
+		{"INTERVAL=1;FREQ=weekly;WKST=MO;BYDAY=TU,TH", "FREQ=WEEKLY;BYDAY=TU,TH"},
+		{"BYSETPOS=-1;BYDAY=MO,TU,WE,TH,FR;FREQ=MONTHLY;INTERVAL=2", "FREQ=MONTHLY;INTERVAL=2;BYDAY=MO,TU,WE,TH,FR;BYSETPOS=-1"},
+		{"FREQ=YEARLY;BYMONTH=11;BYDAY=1SU;UNTIL=20200101", "FREQ=YEARLY;UNTIL=20200101;BYDAY=1SU;BYMONTH=11"},
+		{"FREQ=WEEKLY;UNTIL=20200101T090000;WKST=SU", "FREQ=WEEKLY;UNTIL=20200101T090000;WKST=SU"},
+		{"FREQ=HOURLY;COUNT=5;BYMINUTE=0,30;BYSECOND=15", "FREQ=HOURLY;COUNT=5;BYSECOND=15;BYMINUTE=0,30"},
+	}
+
+	for _, test := range tests {
+		rule, err := ParseRRule(test.rule)
+
+		assert.Nil(t, err)
+		assert.Equal(t, test.expected, rule.String())
+	}
+
+	until := time.Date(2020, 1, 1, 10, 0, 0, 0, time.FixedZone("CET", 3600))
+	assert.Equal(t, "FREQ=DAILY;UNTIL=20200101T090000Z", RRule{Freq: "DAILY", Until: &until, WeekStart: time.Monday}.String())
+}
+
+const invalidRuleICS = `BEGIN:VCALENDAR
+BEGIN:VEVENT
+DTSTAMP:20151116T133227Z
+DTSTART:20190101T090000Z
+DTEND:20190101T100000Z
+UID:invalid@gocal
+RRULE:FREQ=FORTNIGHTLY
+END:VEVENT
+BEGIN:VEVENT
+DTSTAMP:20151116T133227Z
+DTSTART:20190102T090000Z
+DTEND:20190102T100000Z
+UID:valid@gocal
+RRULE:FREQ=DAILY;COUNT=2
+END:VEVENT
+END:VCALENDAR`
+
+func Test_InvalidRRuleStrictModes(t *testing.T) {
+	start, end := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	gc := NewParser(strings.NewReader(invalidRuleICS))
+	gc.Start, gc.End = &start, &end
+	err := gc.Parse()
+
+	assert.IsType(t, &ParseError{}, err)
+	assert.True(t, errors.As(err, &RRuleError{}))
+
+	gc = NewParser(strings.NewReader(invalidRuleICS))
+	gc.Start, gc.End = &start, &end
+	gc.Strict.Mode = StrictModeFailEvent
+	err = gc.Parse()
+
+	assert.Nil(t, err)
+	assert.Len(t, gc.Events, 2)
+	assert.Equal(t, "valid@gocal", gc.Events[0].Uid)
+	assert.Len(t, gc.Warnings, 1)
+
+	gc = NewParser(strings.NewReader(invalidRuleICS))
+	gc.Start, gc.End = &start, &end
+	gc.Strict.Mode = StrictModeFailAttribute
+	err = gc.Parse()
+
+	assert.Nil(t, err)
+	assert.Len(t, gc.Events, 3)
+	assert.Equal(t, "invalid@gocal", gc.Events[0].Uid)
+	assert.False(t, gc.Events[0].Valid)
+	assert.Empty(t, gc.Events[0].RecurrenceRule)
+}
+
+func Test_ExpandAcrossDST(t *testing.T) {
+	paris, _ := time.LoadLocation("Europe/Paris")
+	newYork, _ := time.LoadLocation("America/New_York")
+
+	expand := func(dtstart time.Time, duration time.Duration, rule string, from, to time.Time) []Event {
+		gc := NewParser(nil)
+		gc.Start, gc.End = &from, &to
+
+		rrule, err := ParseRRule(rule)
+		assert.Nil(t, err)
+
+		end := dtstart.Add(duration)
+		return gc.ExpandRecurringEvent(&Event{Uid: "dst@gocal", Start: &dtstart, End: &end, RecurrenceRule: []RRule{*rrule}})
+	}
+
+	// Wall-clock time and duration are kept across the change
+	events := expand(time.Date(2019, 3, 29, 9, 0, 0, 0, paris), time.Hour, "FREQ=DAILY;COUNT=4", time.Date(2019, 3, 1, 0, 0, 0, 0, time.UTC), time.Date(2019, 4, 30, 0, 0, 0, 0, time.UTC))
+	assert.Len(t, events, 4)
+	for _, e := range events {
+		assert.Equal(t, 9, e.Start.Hour())
+		assert.Equal(t, time.Hour, e.End.Sub(*e.Start))
+	}
+	assert.Equal(t, time.Date(2019, 3, 31, 7, 0, 0, 0, time.UTC), events[2].Start.UTC())
+
+	// Nonexistent times are shifted by the gap, ambiguous times are the first of the two
+	events = expand(time.Date(2019, 3, 30, 2, 30, 0, 0, paris), time.Hour, "FREQ=DAILY;COUNT=2", time.Date(2019, 3, 1, 0, 0, 0, 0, time.UTC), time.Date(2019, 4, 30, 0, 0, 0, 0, time.UTC))
+	assert.Equal(t, time.Date(2019, 3, 31, 1, 30, 0, 0, time.UTC), events[1].Start.UTC())
+
+	events = expand(time.Date(2019, 10, 26, 2, 30, 0, 0, paris), time.Hour, "FREQ=DAILY;COUNT=2", time.Date(2019, 10, 1, 0, 0, 0, 0, time.UTC), time.Date(2019, 11, 30, 0, 0, 0, 0, time.UTC))
+	assert.Equal(t, time.Date(2019, 10, 27, 0, 30, 0, 0, time.UTC), events[1].Start.UTC())
+
+	// UNTIL in UTC is compared as an instant: 09:00 in New York is 13:00 UTC after the change
+	events = expand(time.Date(2019, 3, 8, 9, 0, 0, 0, newYork), time.Hour, "FREQ=DAILY;UNTIL=20190311T120000Z", time.Date(2019, 3, 1, 0, 0, 0, 0, time.UTC), time.Date(2019, 4, 30, 0, 0, 0, 0, time.UTC))
+	assert.Len(t, events, 3)
+
+	// Floating and date UNTIL values are taken in the zone of DTSTART, dates including the whole day
+	events = expand(time.Date(2019, 3, 8, 9, 0, 0, 0, newYork), time.Hour, "FREQ=DAILY;UNTIL=20190311T090000", time.Date(2019, 3, 1, 0, 0, 0, 0, time.UTC), time.Date(2019, 4, 30, 0, 0, 0, 0, time.UTC))
+	assert.Len(t, events, 4)
+
+	events = expand(time.Date(2019, 3, 8, 23, 0, 0, 0, newYork), time.Hour, "FREQ=DAILY;UNTIL=20190311", time.Date(2019, 3, 1, 0, 0, 0, 0, time.UTC), time.Date(2019, 4, 30, 0, 0, 0, 0, time.UTC))
+	assert.Len(t, events, 4)
+}
+
+const allDayDSTICS = `BEGIN:VCALENDAR
+BEGIN:VEVENT
+DTSTAMP:20151116T133227Z
+DTSTART;VALUE=DATE:20190329
+DTEND;VALUE=DATE:20190330
+UID:allday@gocal
+RRULE:FREQ=DAILY;COUNT=3
+END:VEVENT
+END:VCALENDAR`
+
+func Test_ExpandAllDayAcrossDST(t *testing.T) {
+	paris, _ := time.LoadLocation("Europe/Paris")
+	start, end := time.Date(2019, 3, 1, 0, 0, 0, 0, time.UTC), time.Date(2019, 4, 30, 0, 0, 0, 0, time.UTC)
+
+	gc := NewParser(strings.NewReader(allDayDSTICS))
+	gc.Start, gc.End = &start, &end
+	gc.AllDayEventsTZ = paris
+	err := gc.Parse()
+
+	assert.Nil(t, err)
+	assert.Len(t, gc.Events, 3)
+	for _, e := range gc.Events {
+		assert.Equal(t, "00:00", e.Start.Format("15:04"))
+		assert.Equal(t, e.Start.Day(), e.End.Day())
+		assert.Equal(t, "23:59:59", e.End.Format("15:04:05"))
+	}
+}
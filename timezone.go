@@ -0,0 +1,279 @@
+package gocal
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/apognu/gocal/parser"
+)
+
+// Observances recurring forever are only expanded up to that year
+const timezoneMaxYear = 2100
+
+func (gc *Gocal) parseTimezone(l *Line) error {
+	if gc.tzBuffer == nil {
+		return nil
+	}
+
+	switch l.Key {
+	case "TZID":
+		gc.tzBuffer.ID = unquoteTZID(l.Value)
+	}
+
+	return nil
+}
+
+func (gc *Gocal) parseTimezoneObservance(l *Line) error {
+	if gc.tzBuffer == nil || len(gc.tzBuffer.Observances) == 0 {
+		return nil
+	}
+
+	o := &gc.tzBuffer.Observances[len(gc.tzBuffer.Observances)-1]
+
+	switch l.Key {
+	case "TZNAME":
+		o.Name = l.Value
+	case "TZOFFSETFROM":
+		offset, err := parser.ParseUTCOffset(l.Value)
+		if err != nil {
+			return err
+		}
+		o.OffsetFrom = offset
+	case "TZOFFSETTO":
+		offset, err := parser.ParseUTCOffset(l.Value)
+		if err != nil {
+			return err
+		}
+		o.OffsetTo = offset
+	case "DTSTART":
+		d, err := parseLocalTime(l.Value)
+		if err != nil {
+			return fmt.Errorf("could not parse: %s", err)
+		}
+		o.Start = d
+	case "RRULE":
+		rule, err := ParseRRule(l.Value)
+		if err != nil {
+			return err
+		}
+		o.RecurrenceRule = rule
+	case "RDATE":
+		// UTC values need TZOFFSETFROM to be converted into local time, process them when the observance is complete
+		o.delayed = append(o.delayed, l)
+	}
+
+	return nil
+}
+
+func (gc *Gocal) finalizeTimezoneObservance() error {
+	o := &gc.tzBuffer.Observances[len(gc.tzBuffer.Observances)-1]
+
+	for _, l := range o.delayed {
+		for _, v := range strings.Split(l.Value, ",") {
+			if strings.HasSuffix(v, "Z") {
+				d, err := time.Parse("20060102T150405Z", v)
+				if err != nil {
+					return fmt.Errorf("could not parse: %s", err)
+				}
+				o.RecurrenceDates = append(o.RecurrenceDates, d.Add(time.Duration(o.OffsetFrom)*time.Second))
+				continue
+			}
+
+			d, err := parseLocalTime(v)
+			if err != nil {
+				return fmt.Errorf("could not parse: %s", err)
+			}
+			o.RecurrenceDates = append(o.RecurrenceDates, d)
+		}
+	}
+
+	o.delayed = nil
+
+	return nil
+}
+
+func (gc *Gocal) finalizeTimezone() error {
+	tz := gc.tzBuffer
+	gc.tzBuffer = nil
+
+	if tz.ID == "" {
+		return fmt.Errorf("could not parse timezone without TZID")
+	}
+	if len(tz.Observances) == 0 {
+		return fmt.Errorf("could not parse timezone %s without STANDARD or DAYLIGHT", tz.ID)
+	}
+
+	location, err := tz.buildLocation()
+	if err != nil {
+		return fmt.Errorf("could not build timezone %s: %s", tz.ID, err)
+	}
+
+	tz.Location = location
+
+	if gc.Timezones == nil {
+		gc.Timezones = make(map[string]*Timezone)
+	}
+	gc.Timezones[tz.ID] = tz
+
+	return nil
+}
+
+func (tz *Timezone) buildLocation() (*time.Location, error) {
+	transitions := make([]parser.ZoneTransition, 0)
+
+	var first *TimezoneObservance
+	var firstWhen time.Time
+
+	for idx, o := range tz.Observances {
+		zone := parser.Zone{Name: o.Name, Offset: o.OffsetTo, DST: o.Daylight}
+
+		for _, onset := range o.onsets() {
+			when := onset.Add(-time.Duration(o.OffsetFrom) * time.Second)
+			if first == nil || when.Before(firstWhen) {
+				first, firstWhen = &tz.Observances[idx], when
+			}
+
+			transitions = append(transitions, parser.ZoneTransition{When: when, Zone: zone})
+		}
+	}
+
+	// Before the first transition, we are in the zone the first observance transitions from
+	initial := parser.Zone{Offset: first.OffsetFrom}
+	for _, o := range tz.Observances {
+		if o.OffsetTo == first.OffsetFrom {
+			initial = parser.Zone{Name: o.Name, Offset: o.OffsetTo, DST: o.Daylight}
+			break
+		}
+	}
+
+	return parser.NewLocation(tz.ID, initial, transitions)
+}
+
+// onsets lists the local times at which the observance takes effect, from DTSTART, RDATE and RRULE.
+// DTSTART is always the first onset, even when it does not match the rule.
+func (o *TimezoneObservance) onsets() []time.Time {
+	set := &RecurrenceSet{Start: o.Start, Dates: append([]time.Time{o.Start}, o.RecurrenceDates...)}
+
+	if o.RecurrenceRule != nil {
+		rule := *o.RecurrenceRule
+
+		// UNTIL is given in UTC, while onsets are local times
+		if rule.Until != nil && rule.untilLayout == untilLayoutUTC {
+			until := rule.Until.Add(time.Duration(o.OffsetFrom) * time.Second)
+			rule.Until, rule.untilLayout = &until, untilLayoutFloating
+		}
+
+		set.Rules = []RRule{rule}
+	}
+
+	return set.Between(time.Time{}, time.Date(timezoneMaxYear, time.December, 31, 23, 59, 59, 0, time.UTC))
+}
+
+// parseTime parses a date-time value, resolving its TZID against the VTIMEZONEs of the feed first.
+func (gc *Gocal) parseTime(s string, params map[string]string, ty int, allday bool) (*time.Time, error) {
+	if tzid, ok := params["TZID"]; ok && params["VALUE"] != "DATE" && len(s) != 8 && !strings.HasSuffix(s, "Z") {
+		if tz, ok := gc.Timezones[unquoteTZID(tzid)]; ok {
+			d, err := time.ParseInLocation("20060102T150405", s, tz.Location)
+
+			return &d, err
+		}
+
+		if _, err := parser.LoadLocation(tzid); err != nil {
+			// The VTIMEZONE may still come later in the feed
+			if gc.component != nil && !gc.ended {
+				if gc.missingTimezones == nil {
+					gc.missingTimezones = make(map[string]bool)
+				}
+				gc.missingTimezones[unquoteTZID(tzid)] = true
+			} else {
+				gc.warn(SeverityWarning, fmt.Errorf("unknown timezone %s, falling back to UTC", tzid))
+			}
+		}
+	}
+
+	return parser.ParseTime(s, params, ty, allday, gc.AllDayEventsTZ)
+}
+
+// deferredComponent holds the lines of a component using TZIDs whose VTIMEZONE was not parsed yet.
+type deferredComponent struct {
+	lines     []*Line
+	timezones map[string]bool
+	warnings  int // Where the warnings of the component go in Gocal.Warnings
+}
+
+var deferrableComponents = map[string]bool{
+	"VEVENT":    true,
+	"VTODO":     true,
+	"VJOURNAL":  true,
+	"VFREEBUSY": true,
+}
+
+// deferComponent sets the component that just ended aside. The warnings it raised are dropped,
+// except for unparsable lines, since it will be processed again.
+func (gc *Gocal) deferComponent(lines []*Line) {
+	gc.Warnings = append(gc.Warnings[:gc.componentWarnings], gc.lineWarnings...)
+	gc.deferred = append(gc.deferred, &deferredComponent{lines: lines, timezones: gc.missingTimezones, warnings: gc.componentWarnings})
+	gc.missingTimezones = nil
+}
+
+// replayDeferred processes the deferred components whose VTIMEZONEs are now all known,
+// or all of them once the feed is over. Their warnings are put back where the components were.
+func (gc *Gocal) replayDeferred() error {
+	if len(gc.deferred) == 0 {
+		return nil
+	}
+
+	ctx, line, component := gc.ctx, gc.line, gc.component
+	defer func() {
+		gc.ctx, gc.line, gc.component = ctx, line, component
+	}()
+
+	deferred := gc.deferred
+	gc.deferred = nil
+
+	for i, c := range deferred {
+		if !gc.ended && !c.resolved(gc.Timezones) {
+			gc.deferred = append(gc.deferred, c)
+			continue
+		}
+
+		from := len(gc.Warnings)
+
+		gc.ctx, gc.component = &Context{Value: ContextRoot}, nil
+		for _, l := range c.lines {
+			if err := gc.processLine(l); err != nil {
+				return err
+			}
+		}
+
+		raised := append([]Diagnostic(nil), gc.Warnings[from:]...)
+		gc.Warnings = append(gc.Warnings[:c.warnings], append(raised, gc.Warnings[c.warnings:from]...)...)
+
+		for j, d := range deferred {
+			if d.warnings > c.warnings || (j > i && d.warnings == c.warnings) {
+				d.warnings += len(raised)
+			}
+		}
+	}
+
+	return nil
+}
+
+func (c *deferredComponent) resolved(timezones map[string]*Timezone) bool {
+	for tzid := range c.timezones {
+		if _, ok := timezones[tzid]; !ok {
+			return false
+		}
+	}
+
+	return true
+}
+
+func parseLocalTime(s string) (time.Time, error) {
+	return time.ParseInLocation("20060102T150405", s, time.UTC)
+}
+
+func unquoteTZID(tzid string) string {
+	return strings.Trim(tzid, `"`)
+}
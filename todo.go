@@ -0,0 +1,224 @@
+package gocal
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func (gc *Gocal) parseTodo(l *Line) error {
+	// If this is nil, that means we did not get a BEGIN:VTODO
+	if gc.todoBuffer == nil {
+		return nil
+	}
+
+	switch l.Key {
+	case "UID":
+		if err := resolve(gc, l, &gc.todoBuffer.Uid, resolveString, nil); err != nil {
+			return err
+		}
+	case "SUMMARY":
+		if err := resolve(gc, l, &gc.todoBuffer.Summary, resolveString, nil); err != nil {
+			return err
+		}
+	case "DESCRIPTION":
+		if err := resolve(gc, l, &gc.todoBuffer.Description, resolveString, nil); err != nil {
+			return err
+		}
+	case "DTSTART":
+		if err := resolve(gc, l, &gc.todoBuffer.Start, resolveDate, func(gc *Gocal, out *time.Time) {
+			gc.todoBuffer.RawStart = RawDate{Value: l.Value, Params: l.Params}
+		}); err != nil {
+			return err
+		}
+	case "DUE":
+		if err := resolve(gc, l, &gc.todoBuffer.Due, resolveDate, func(gc *Gocal, out *time.Time) {
+			gc.todoBuffer.RawDue = RawDate{Value: l.Value, Params: l.Params}
+		}); err != nil {
+			return err
+		}
+	case "DURATION":
+		// The DURATION attribute should imply DUE as DTSTART+DURATION.
+		// If we have not processed DTSTART yet, add this to the delayed attributes to be processed later.
+		if gc.todoBuffer.Start == nil {
+			gc.todoBuffer.delayed = append(gc.todoBuffer.delayed, l)
+			return nil
+		}
+
+		if err := resolve(gc, l, &gc.todoBuffer.Duration, resolveDuration, func(gc *Gocal, out *time.Duration) {
+			if out != nil {
+				due := gc.todoBuffer.Start.Add(*out)
+				gc.todoBuffer.Due = &due
+			}
+		}); err != nil {
+			return err
+		}
+	case "COMPLETED":
+		if err := resolve(gc, l, &gc.todoBuffer.Completed, resolveDate, nil); err != nil {
+			return err
+		}
+	case "PERCENT-COMPLETE":
+		if err := resolve(gc, l, &gc.todoBuffer.PercentComplete, resolveInt, nil); err != nil {
+			return err
+		}
+	case "PRIORITY":
+		if err := resolve(gc, l, &gc.todoBuffer.Priority, resolveInt, nil); err != nil {
+			return err
+		}
+	case "DTSTAMP":
+		if err := resolve(gc, l, &gc.todoBuffer.Stamp, resolveDate, nil); err != nil {
+			return err
+		}
+	case "CREATED":
+		if err := resolve(gc, l, &gc.todoBuffer.Created, resolveDate, nil); err != nil {
+			return err
+		}
+	case "LAST-MODIFIED":
+		if err := resolve(gc, l, &gc.todoBuffer.LastModified, resolveDate, nil); err != nil {
+			return err
+		}
+	case "RRULE":
+		if err := resolve(gc, l, &gc.todoBuffer.RecurrenceRule, resolveRRule, func(gc *Gocal, _ *RRule) {
+			gc.todoBuffer.IsRecurring = true
+		}); err != nil {
+			return err
+		}
+	case "EXDATE":
+		dates, days := gc.parseExcludeDates(l)
+		gc.todoBuffer.ExcludeDates = append(gc.todoBuffer.ExcludeDates, dates...)
+		gc.todoBuffer.ExcludeDays = append(gc.todoBuffer.ExcludeDays, days...)
+	case "SEQUENCE":
+		gc.todoBuffer.Sequence, _ = strconv.Atoi(l.Value)
+	case "LOCATION":
+		if err := resolve(gc, l, &gc.todoBuffer.Location, resolveString, nil); err != nil {
+			return err
+		}
+	case "STATUS":
+		if err := resolve(gc, l, &gc.todoBuffer.Status, resolveString, nil); err != nil {
+			return err
+		}
+	case "ORGANIZER":
+		if err := resolve(gc, l, &gc.todoBuffer.Organizer, resolveOrganizer, nil); err != nil {
+			return err
+		}
+	case "ATTENDEE":
+		gc.todoBuffer.Attendees = append(gc.todoBuffer.Attendees, parseAttendee(l))
+	case "CATEGORIES":
+		gc.todoBuffer.Categories = strings.Split(l.Value, ",")
+	case "URL":
+		gc.todoBuffer.URL = l.Value
+	case "CLASS":
+		gc.todoBuffer.Class = l.Value
+	default:
+		key := strings.ToUpper(l.Key)
+		if strings.HasPrefix(key, "X-") {
+			if gc.todoBuffer.CustomAttributes == nil {
+				gc.todoBuffer.CustomAttributes = make(map[string]string)
+			}
+			gc.todoBuffer.CustomAttributes[key] = l.Value
+		}
+	}
+
+	return nil
+}
+
+func (gc *Gocal) finalizeTodo() error {
+	for _, d := range gc.todoBuffer.delayed {
+		gc.parseTodo(d)
+	}
+
+	if err := gc.checkTodo(); err != nil {
+		switch gc.Strict.Mode {
+		case StrictModeFailFeed:
+			return err
+		case StrictModeFailEvent:
+			gc.warn(SeverityError, err)
+			return nil
+		}
+		gc.warn(SeverityError, err)
+	}
+
+	if gc.Strict.Mode == StrictModeFailEvent && !gc.todoBuffer.Valid {
+		return nil
+	}
+
+	if gc.todoBuffer.IsRecurring && (gc.todoBuffer.Start != nil || gc.todoBuffer.Due != nil) {
+		gc.Todos = append(gc.Todos, gc.ExpandRecurringTodo(gc.todoBuffer)...)
+		return nil
+	}
+
+	if !gc.SkipBounds && !gc.IsTodoInRange(*gc.todoBuffer) {
+		return nil
+	}
+
+	gc.Todos = append(gc.Todos, *gc.todoBuffer)
+
+	return nil
+}
+
+func (gc *Gocal) checkTodo() error {
+	if gc.todoBuffer.Uid == "" {
+		gc.todoBuffer.Valid = false
+		return fmt.Errorf("could not parse todo without UID")
+	}
+	if gc.todoBuffer.Stamp == nil {
+		gc.todoBuffer.Valid = false
+		return fmt.Errorf("could not parse todo without DTSTAMP")
+	}
+	if gc.todoBuffer.RawDue.Value != "" && gc.todoBuffer.Duration != nil {
+		return fmt.Errorf("only one of DUE and DURATION must be provided")
+	}
+
+	return nil
+}
+
+// IsTodoInRange checks a todo against the parsing range, using its DTSTART and DUE dates.
+// Todos without any of those are not bound to a date and are always in range.
+func (gc *Gocal) IsTodoInRange(t Todo) bool {
+	switch {
+	case t.Start != nil && t.Due != nil:
+		return gc.IsInRange(Event{Start: t.Start, End: t.Due})
+	case t.Due != nil:
+		return !t.Due.Before(*gc.Start) && !t.Due.After(*gc.End)
+	case t.Start != nil:
+		return !t.Start.Before(*gc.Start) && !t.Start.After(*gc.End)
+	default:
+		return true
+	}
+}
+
+// ExpandRecurringTodo expands a recurring todo the same way as events, shifting both its DTSTART and DUE dates.
+func (gc *Gocal) ExpandRecurringTodo(buf *Todo) []Todo {
+	start, end := buf.Start, buf.Due
+	if start == nil {
+		start = end
+	}
+	if end == nil {
+		end = start
+	}
+
+	instances := gc.ExpandRecurringEvent(&Event{
+		Uid:            buf.Uid,
+		Start:          start,
+		End:            end,
+		RecurrenceRule: []RRule{*buf.RecurrenceRule},
+		ExcludeDates:   buf.ExcludeDates,
+		ExcludeDays:    buf.ExcludeDays,
+	})
+
+	todos := make([]Todo, 0, len(instances))
+	for _, i := range instances {
+		t := *buf
+		if buf.Start != nil {
+			t.Start = i.Start
+		}
+		if buf.Due != nil {
+			t.Due = i.End
+		}
+
+		todos = append(todos, t)
+	}
+
+	return todos
+}
@@ -3,10 +3,9 @@ package gocal
 import (
 	"bufio"
 	"fmt"
+	"sort"
 	"strings"
 	"time"
-
-	"github.com/apognu/gocal/parser"
 )
 
 const (
@@ -29,6 +28,19 @@ type DuplicateParams struct {
 	Mode int
 }
 
+const (
+	RecurrenceModeExpand = iota
+	RecurrenceModeMasters
+)
+
+// RecurrenceParams controls the expansion of recurring events. With RecurrenceModeMasters, recurring events
+// and their overrides are returned as they are, to be expanded on demand with Gocal.Occurrences().
+// MaxInstances, if not 0, limits the number of instances of each recurring event.
+type RecurrenceParams struct {
+	Mode         int
+	MaxInstances int
+}
+
 type DuplicateAttributeError struct {
 	Key, Value string
 }
@@ -41,25 +53,133 @@ func (err DuplicateAttributeError) Error() string {
 	return fmt.Sprintf("duplicate attribute %s: %s", err.Key, err.Value)
 }
 
+// RRuleError is returned for recurrence rules that do not conform to RFC 5545.
+type RRuleError struct {
+	Value, Reason string
+}
+
+func NewRRuleError(v, reason string, args ...interface{}) RRuleError {
+	return RRuleError{Value: v, Reason: fmt.Sprintf(reason, args...)}
+}
+
+func (err RRuleError) Error() string {
+	return fmt.Sprintf("invalid recurrence rule %s: %s", err.Value, err.Reason)
+}
+
+// ParseError locates a parsing failure in the feed. Line is the physical line
+// number the property started on, LogicalLine its number once unfolded.
+type ParseError struct {
+	Line        int
+	LogicalLine int
+	Component   string
+	Property    string
+	Uid         string
+	Err         error
+}
+
+func (err *ParseError) Error() string {
+	where := fmt.Sprintf("line %d", err.Line)
+	if err.Component != "" {
+		where += " in " + err.Component
+		if err.Uid != "" {
+			where += fmt.Sprintf(" (UID %s)", err.Uid)
+		}
+	}
+	if err.Property != "" {
+		where += ", property " + err.Property
+	}
+
+	return fmt.Sprintf("gocal error: %s: %s", where, err.Err)
+}
+
+func (err *ParseError) Unwrap() error {
+	return err.Err
+}
+
+const (
+	SeverityWarning = iota
+	SeverityError
+)
+
+// Diagnostic records a problem the parser recovered from instead of aborting the feed.
+// Warnings are approximated or dropped values, errors are components skipped or marked invalid.
+type Diagnostic struct {
+	ParseError
+	Severity int
+}
+
 type Gocal struct {
 	scanner        *bufio.Scanner
 	Events         []Event
+	Todos          []Todo
+	Journals       []Journal
+	FreeBusy       []FreeBusy
 	SkipBounds     bool
 	Strict         StrictParams
 	Duplicate      DuplicateParams
+	Recurrence     RecurrenceParams
 	buffer         *Event
+	todoBuffer     *Todo
+	journalBuffer  *Journal
+	freeBusyBuffer *FreeBusy
 	Start          *time.Time
 	End            *time.Time
 	Method         string
 	AllDayEventsTZ *time.Location
+	Timezones      map[string]*Timezone
+	Lossless       bool
+	Warnings       []Diagnostic
+	Lines          Lines
+	eol            string
+	pending        string
+	physicalLine   int
+	logicalLine    int
+
+	ctx                *Context
+	line               *Line
+	recurringInstances []Event
+	masters            map[string]*Event
+	pendingOverrides   []Event
+	overrides          map[string]*overrideIndex
+	overriddenUids     []string
+	pendingUids        map[string]bool
+	held               []Event
+	heldUid            string
+	released           map[string]bool
+	streaming          bool
+	queue              []Event
+	ended              bool
+	err                error
+	tzBuffer           *Timezone
+	component          []*Line
+	componentWarnings  int
+	lineWarnings       []Diagnostic
+	missingTimezones   map[string]bool
+	deferred           []*deferredComponent
 }
 
 const (
 	ContextRoot = iota
 	ContextEvent
 	ContextUnknown
+	ContextTimezone
+	ContextTimezoneObservance
+	ContextTodo
+	ContextJournal
+	ContextAlarm
+	ContextFreeBusy
 )
 
+var contextNames = map[int]string{
+	ContextEvent:              "VEVENT",
+	ContextTimezone:           "VTIMEZONE",
+	ContextTimezoneObservance: "VTIMEZONE",
+	ContextTodo:               "VTODO",
+	ContextJournal:            "VJOURNAL",
+	ContextAlarm:              "VALARM",
+	ContextFreeBusy:           "VFREEBUSY",
+}
+
 type Context struct {
 	Value    int
 	Previous *Context
@@ -69,31 +189,107 @@ func (ctx *Context) Nest(value int) *Context {
 	return &Context{Value: value, Previous: ctx}
 }
 
+// Within checks if the context, or any of its parents, has the given value.
+func (ctx *Context) Within(value int) bool {
+	for c := ctx; c != nil; c = c.Previous {
+		if c.Value == value {
+			return true
+		}
+	}
+	return false
+}
+
 func (gc *Gocal) IsInRange(d Event) bool {
-	if (d.Start.Before(*gc.Start) && d.End.After(*gc.Start)) ||
-		(d.Start.After(*gc.Start) && d.End.Before(*gc.End)) ||
-		(d.Start.Before(*gc.End) && d.End.After(*gc.End)) {
+	return isInRange(d, *gc.Start, *gc.End)
+}
+
+func isInRange(d Event, start, end time.Time) bool {
+	if (d.Start.Before(start) && d.End.After(start)) ||
+		(d.Start.After(start) && d.End.Before(end)) ||
+		(d.Start.Before(end) && d.End.After(end)) {
 		return true
 	}
 	return false
 }
 
+// IsRecurringInstanceOverriden checks if an instance was replaced by an override, either for that instance
+// or with RANGE=THISANDFUTURE from an earlier one.
 func (gc *Gocal) IsRecurringInstanceOverriden(instance *Event) bool {
-	for _, e := range gc.Events {
-		if e.Uid == instance.Uid {
-			rid, _ := parser.ParseTime(e.RecurrenceID, e.RawStart.Params, parser.TimeStart, false, gc.AllDayEventsTZ)
-			if rid.Equal(*instance.Start) {
-				return true
-			}
+	idx, ok := gc.overrides[instance.Uid]
+	if !ok {
+		return false
+	}
+
+	_, exact := idx.exact[instance.Start.UnixNano()]
+
+	return exact || idx.future(*instance.Start) != nil
+}
+
+// recordMaster keeps a recurring event by UID, so its overrides can refer to it.
+func (gc *Gocal) recordMaster(e *Event) {
+	if gc.masters == nil {
+		gc.masters = make(map[string]*Event)
+	}
+
+	gc.masters[e.Uid] = e
+}
+
+// overrideIndex holds the overrides of a recurring event, by RECURRENCE-ID.
+type overrideIndex struct {
+	all   []*Event
+	exact map[int64]*Event
+
+	// Overrides with RANGE=THISANDFUTURE, sorted by RECURRENCE-ID
+	thisAndFuture []Event
+}
+
+// future returns the THISANDFUTURE override applying to an instance, the latest one with an earlier RECURRENCE-ID.
+func (idx *overrideIndex) future(start time.Time) *Event {
+	for i := len(idx.thisAndFuture) - 1; i >= 0; i-- {
+		if idx.thisAndFuture[i].RecurrenceID.Before(start) {
+			return &idx.thisAndFuture[i]
 		}
 	}
-	return false
+
+	return nil
+}
+
+// recordOverride indexes an override by UID and RECURRENCE-ID, so it can be matched against recurring instances.
+func (gc *Gocal) recordOverride(e Event) {
+	if gc.overrides == nil {
+		gc.overrides = make(map[string]*overrideIndex)
+	}
+
+	idx, ok := gc.overrides[e.Uid]
+	if !ok {
+		idx = &overrideIndex{exact: make(map[int64]*Event)}
+		gc.overrides[e.Uid] = idx
+		gc.overriddenUids = append(gc.overriddenUids, e.Uid)
+	}
+
+	idx.all = append(idx.all, &e)
+	idx.exact[e.RecurrenceID.UnixNano()] = &e
+
+	if e.RawRecurrenceID.Params["RANGE"] == RecurrenceRangeThisAndFuture {
+		idx.thisAndFuture = append(idx.thisAndFuture, e)
+		sort.SliceStable(idx.thisAndFuture, func(i, j int) bool {
+			return idx.thisAndFuture[i].RecurrenceID.Before(*idx.thisAndFuture[j].RecurrenceID)
+		})
+	}
 }
 
 type Line struct {
 	Key    string
 	Params map[string]string
 	Value  string
+
+	// Position of the line in the feed, as physical (folded) and logical (unfolded) line numbers
+	number        int
+	logicalNumber int
+
+	// Only set in lossless mode, the line as it was read and a copy of its parsed value
+	raw      string
+	original *Line
 }
 
 func (l *Line) Is(key, value string) bool {
@@ -111,6 +307,11 @@ func (l *Line) IsValue(value string) bool {
 	return strings.TrimSpace(l.Value) == value
 }
 
+const StatusCancelled = "CANCELLED"
+
+// RANGE parameter of a RECURRENCE-ID applying an override to every later instance
+const RecurrenceRangeThisAndFuture = "THISANDFUTURE"
+
 type RawDate struct {
 	Params map[string]string
 	Value  string
@@ -119,6 +320,9 @@ type RawDate struct {
 type Event struct {
 	delayed []*Line
 
+	// Only set in lossless mode, all the lines from BEGIN:VEVENT to END:VEVENT, including nested components
+	Lines Lines
+
 	Uid              string
 	Summary          string
 	Description      string
@@ -138,10 +342,17 @@ type Event struct {
 	Organizer        *Organizer
 	Attendees        []Attendee
 	Attachments      []Attachment
+	Alarms           []Alarm
 	IsRecurring      bool
-	RecurrenceID     string
-	RecurrenceRule   map[string]string
+	RecurrenceID     *time.Time
+	RawRecurrenceID  RawDate
+	OccurrenceStart  *time.Time
+	Master           *Event
+	RecurrenceRule   []RRule
+	ExcludeRules     []RRule
+	RecurrenceDates  []RecurrenceDate
 	ExcludeDates     []time.Time
+	ExcludeDays      []time.Time
 	Sequence         int
 	CustomAttributes map[string]string
 	Valid            bool
@@ -149,6 +360,197 @@ type Event struct {
 	Class            string
 }
 
+// RRule is a recurrence rule. Empty BYxxx parts are not used, and a zero Interval or Count
+// is the same as 1 and no limit. Rules parsed without WKST have their WeekStart set to monday.
+// Reference: https://icalendar.org/iCalendar-RFC-5545/3-3-10-recurrence-rule.html
+type RRule struct {
+	Freq       string
+	Interval   int
+	Count      int
+	Until      *time.Time
+	WeekStart  time.Weekday
+	ByDay      []WeekdayNum
+	ByMonth    []int
+	ByMonthDay []int
+	ByYearDay  []int
+	ByWeekNo   []int
+	ByHour     []int
+	ByMinute   []int
+	BySecond   []int
+	BySetPos   []int
+
+	// Layout UNTIL was given in, a date, floating or UTC date-time
+	untilLayout string
+}
+
+// WeekdayNum is a BYDAY value, with an optional ordinal: 2TU is the second tuesday and -1FR the last friday of the period.
+type WeekdayNum struct {
+	N   int
+	Day time.Weekday
+}
+
+// RecurrenceSet is the set of occurrences defined by a DTSTART, RRULEs and RDATEs, minus EXRULEs and EXDATEs.
+// Without any rule, DTSTART is an occurrence of the set. ExcludeDays holds VALUE=DATE EXDATEs, which remove
+// every occurrence on that calendar day, in the zone of DTSTART; only their date is used.
+type RecurrenceSet struct {
+	Start        time.Time
+	Rules        []RRule
+	ExcludeRules []RRule
+	Dates        []time.Time
+	ExcludeDates []time.Time
+	ExcludeDays  []time.Time
+}
+
+// IsOccurrence checks if the event is an instance of a recurring event, either expanded from its rules or overridden.
+// OccurrenceStart then identifies the instance, along with the UID, as the start given to it by the recurrence.
+func (e Event) IsOccurrence() bool {
+	return e.OccurrenceStart != nil
+}
+
+// IsOverride checks if the event replaces an instance of a recurring event, through a RECURRENCE-ID.
+func (e Event) IsOverride() bool {
+	return e.RecurrenceID != nil
+}
+
+// RecurrenceDate is an occurrence added by RDATE. End is only set for VALUE=PERIOD values.
+type RecurrenceDate struct {
+	Start time.Time
+	End   *time.Time
+}
+
+const (
+	TriggerRelatedStart = "START"
+	TriggerRelatedEnd   = "END"
+)
+
+type Alarm struct {
+	Action           string
+	Trigger          *Trigger
+	Repeat           int
+	Duration         *time.Duration
+	Description      string
+	Summary          string
+	Attendees        []Attendee
+	Attachments      []Attachment
+	CustomAttributes map[string]string
+}
+
+// A trigger is either relative to the start or end of the event, or an absolute date and time.
+type Trigger struct {
+	Related  string
+	Duration *time.Duration
+	Time     *time.Time
+}
+
+type AlarmOccurrence struct {
+	Event *Event
+	Alarm *Alarm
+	Time  time.Time
+}
+
+type Todo struct {
+	delayed []*Line
+
+	Uid              string
+	Summary          string
+	Description      string
+	Categories       []string
+	Start            *time.Time
+	RawStart         RawDate
+	Due              *time.Time
+	RawDue           RawDate
+	Duration         *time.Duration
+	Completed        *time.Time
+	PercentComplete  int
+	Priority         int
+	Stamp            *time.Time
+	Created          *time.Time
+	LastModified     *time.Time
+	Location         string
+	URL              string
+	Status           string
+	Organizer        *Organizer
+	Attendees        []Attendee
+	IsRecurring      bool
+	RecurrenceRule   *RRule
+	ExcludeDates     []time.Time
+	ExcludeDays      []time.Time
+	Sequence         int
+	CustomAttributes map[string]string
+	Valid            bool
+	Class            string
+}
+
+type Journal struct {
+	Uid              string
+	Summary          string
+	Descriptions     []string
+	Categories       []string
+	Start            *time.Time
+	RawStart         RawDate
+	Stamp            *time.Time
+	Created          *time.Time
+	LastModified     *time.Time
+	URL              string
+	Status           string
+	Organizer        *Organizer
+	Attendees        []Attendee
+	Attachments      []Attachment
+	IsRecurring      bool
+	RecurrenceRule   *RRule
+	ExcludeDates     []time.Time
+	ExcludeDays      []time.Time
+	Sequence         int
+	CustomAttributes map[string]string
+	Valid            bool
+	Class            string
+}
+
+const (
+	FreeBusyTypeFree            = "FREE"
+	FreeBusyTypeBusy            = "BUSY"
+	FreeBusyTypeBusyUnavailable = "BUSY-UNAVAILABLE"
+	FreeBusyTypeBusyTentative   = "BUSY-TENTATIVE"
+)
+
+type FreeBusy struct {
+	Uid              string
+	Stamp            *time.Time
+	Start            *time.Time
+	End              *time.Time
+	Organizer        *Organizer
+	Attendees        []Attendee
+	Periods          []FreeBusyPeriod
+	URL              string
+	Comment          string
+	CustomAttributes map[string]string
+	Valid            bool
+}
+
+type FreeBusyPeriod struct {
+	Type  string
+	Start time.Time
+	End   time.Time
+}
+
+type Timezone struct {
+	ID          string
+	Observances []TimezoneObservance
+	Location    *time.Location
+}
+
+type TimezoneObservance struct {
+	delayed []*Line
+
+	Daylight        bool
+	Name            string
+	OffsetFrom      int
+	OffsetTo        int
+	Start           time.Time
+	RecurrenceRule  *RRule
+	RecurrenceDates []time.Time
+}
+
 type Geo struct {
 	Lat  float64
 	Long float64